@@ -1,9 +1,12 @@
 package main
 
 import (
+	"net/http"
+	"net/url"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // Test connectivity with both TCP and UDP protocols
@@ -24,9 +27,10 @@ func TestConnectivityWithTCPAndUDPProtocols(t *testing.T) {
 		Domain:       "example.com",
 	}
 	i := 0
+	ensureConfigIDs(setting.Configs)
 
 	// Run the function under test
-	TestSingleConfig(setting, i)
+	TestSingleConfig(setting, setting.Configs[i].ID)
 
 	// Assert the results
 	assert.Equal(t, 2, len(setting.Configs[i].TestReports))
@@ -37,7 +41,29 @@ func TestConnectivityWithTCPAndUDPProtocols(t *testing.T) {
 }
 
 func TestCollectReport(t *testing.T) {
-	r := connectivityReport{Resolver: "8.8.8.8", Proto: "tcp", Transport: "testss://", Error: nil, Collected: false}
+	r := connectivityReport{Resolver: "8.8.8.8", Proto: "tcp", Transport: "testss://", Error: nil}
 	u := "https://script.google.com/macros/s/AKfycbzoMBmftQaR9Aw4jzTB-w4TwkDjLHtSfBCFhh4_2NhTEZAUdj85Qt8uYCKCNOEAwCg4/exec"
-	collectReport(&r, u)
+	collectReport(&r, (&AppSettings{ReporterURL: u, ReportPrivacy: defaultReportPrivacy}).reportSettings())
+}
+
+func TestSingleConfigReturnsErrors(t *testing.T) {
+	setting := &AppSettings{Configs: []Config{{ID: "bad", Transport: "ss://not a config", Health: 1}}}
+	err := TestSingleConfig(setting, "bad")
+	assert.Error(t, err)
+	assert.Equal(t, 3, setting.Configs[0].Health)
+
+	assert.Error(t, TestSingleConfig(setting, "removed"))
+}
+
+func TestReportHTTPClient(t *testing.T) {
+	assert.Nil(t, reportHTTPClient(true).Transport, "no tunnel is running")
+
+	setRunningProxy(&runningProxy{Address: "127.0.0.1:8080"})
+	defer setRunningProxy(nil)
+	assert.Nil(t, reportHTTPClient(false).Transport)
+	transport, ok := reportHTTPClient(true).Transport.(*http.Transport)
+	require.True(t, ok)
+	proxyURL, err := transport.Proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "collector.example.com"}})
+	require.NoError(t, err)
+	assert.Equal(t, "http://127.0.0.1:8080", proxyURL.String())
 }
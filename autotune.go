@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// splitPositions are the byte offsets tried with the split: wrapper.
+var splitPositions = []int{1, 2, 3, 5, 10}
+
+// tlsFragLengths are the record lengths tried with the tlsfrag: wrapper.
+var tlsFragLengths = []int{1, 3, 10}
+
+// ssPrefixes are Shadowsocks connection prefixes that make the stream look like
+// a common protocol. See https://www.reddit.com/r/outlinevpn/wiki/index/prefixing/
+var ssPrefixes = []string{
+	"HTTP/1.1 ",
+	"POST ",
+	"\x16\x03\x01\x00\xa8\x01\x01",
+	"\x13\x03\x03\x3F",
+	"\x05\x00\x01",
+}
+
+// autoTuneCandidates generates transport chains around the original transport
+// that may get past DPI: split and TLS fragmentation wrappers, and for
+// Shadowsocks, prefix variants on their own and combined with split.
+func autoTuneCandidates(transportConfig string) []string {
+	transportConfig = strings.TrimSpace(transportConfig)
+	var candidates []string
+	for _, n := range splitPositions {
+		candidates = append(candidates, fmt.Sprintf("split:%d|%v", n, transportConfig))
+	}
+	for _, n := range tlsFragLengths {
+		candidates = append(candidates, fmt.Sprintf("tlsfrag:%d|%v", n, transportConfig))
+	}
+	parts := strings.Split(transportConfig, "|")
+	last := parts[len(parts)-1]
+	u, err := url.Parse(last)
+	if err != nil || u.Scheme != "ss" {
+		return candidates
+	}
+	for _, prefix := range ssPrefixes {
+		q := u.Query()
+		q.Set("prefix", encodePrefix(prefix))
+		variant := *u
+		variant.RawQuery = q.Encode()
+		if variant.Path == "" {
+			variant.Path = "/"
+		}
+		parts[len(parts)-1] = variant.String()
+		prefixed := strings.Join(parts, "|")
+		candidates = append(candidates, prefixed, fmt.Sprintf("split:%d|%v", splitPositions[0], prefixed))
+	}
+	return candidates
+}
+
+// encodePrefix maps each prefix byte to the rune of the same value,
+// which is how the prefix URL parameter is decoded.
+func encodePrefix(prefix string) string {
+	runes := make([]rune, len(prefix))
+	for i := 0; i < len(prefix); i++ {
+		runes[i] = rune(prefix[i])
+	}
+	return string(runes)
+}
+
+// autoTuneTester tests the candidates of AutoTune. Tests replace it to stub
+// the connectivity engine.
+var autoTuneTester = TestConfigs
+
+// AutoTune tests the candidate chains of the config with the given ID with the
+// connectivity engine and returns the working ones, healthiest and fastest first.
+func AutoTune(setting *AppSettings, id string) ([]Config, error) {
+	c, ok := setting.configByID(id)
+	if !ok {
+		return nil, fmt.Errorf("the config was removed")
+	}
+	trial := AppSettings{Domain: setting.Domain, ResolverHost: setting.ResolverHost, Configs: []Config{}}
+	for _, candidate := range autoTuneCandidates(c.Transport) {
+		if err := validateTransport(candidate); err != nil {
+			debugLog.Printf("Skipping invalid auto-tune candidate: %v", err)
+			continue
+		}
+		trial.Configs = append(trial.Configs, Config{Transport: candidate, TestReports: []*connectivityReport{}})
+	}
+	if err := autoTuneTester(&trial); err != nil {
+		log.Printf("Some auto-tune candidates were not tested: %v", err)
+	}
+
+	// split: and tlsfrag: have no UDP support, so only TCP has to pass.
+	var working []Config
+	for _, c := range trial.Configs {
+		if passedProto(c, "tcp") {
+			working = append(working, c)
+		}
+	}
+	sort.SliceStable(working, func(a, b int) bool {
+		if working[a].Health != working[b].Health {
+			return working[a].Health < working[b].Health
+		}
+		return totalDurationMs(working[a]) < totalDurationMs(working[b])
+	})
+	return working, nil
+}
+
+// passedProto reports whether the config has a successful test report for proto.
+func passedProto(c Config, proto string) bool {
+	for _, r := range c.TestReports {
+		if r.Proto == proto && r.IsSuccess() {
+			return true
+		}
+	}
+	return false
+}
+
+// totalDurationMs sums the test durations of a config's reports.
+func totalDurationMs(c Config) int64 {
+	var total int64
+	for _, r := range c.TestReports {
+		total += r.DurationMs
+	}
+	return total
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Jigsaw-Code/outline-sdk/x/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoTuneCandidates(t *testing.T) {
+	ss := "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpwYXNz@example.com:443"
+	candidates := autoTuneCandidates(ss)
+
+	assert.Contains(t, candidates, "split:1|"+ss)
+	assert.Contains(t, candidates, "tlsfrag:1|"+ss)
+	var prefixed int
+	for _, c := range candidates {
+		parts := strings.Split(c, "|")
+		u, err := url.Parse(parts[len(parts)-1])
+		require.NoError(t, err)
+		assert.Equal(t, "example.com:443", u.Host)
+		if u.Query().Get("prefix") != "" {
+			prefixed++
+		}
+	}
+	assert.Equal(t, 2*len(ssPrefixes), prefixed)
+	for _, c := range candidates {
+		_, err := config.NewStreamDialer(c)
+		assert.NoError(t, err, c)
+	}
+}
+
+func TestAutoTuneCandidatesNonShadowsocks(t *testing.T) {
+	candidates := autoTuneCandidates("socks5://example.com:1080")
+	assert.Len(t, candidates, len(splitPositions)+len(tlsFragLengths))
+}
+
+func TestAutoTuneRanksWorkingCandidates(t *testing.T) {
+	original := autoTuneTester
+	t.Cleanup(func() { autoTuneTester = original })
+	var tested []string
+	autoTuneTester = func(trial *AppSettings) error {
+		for i := range trial.Configs {
+			c := &trial.Configs[i]
+			tested = append(tested, c.Transport)
+			tcp := &connectivityReport{Proto: "tcp", DurationMs: 300}
+			udp := &connectivityReport{Proto: "udp", DurationMs: 300, Error: &errorJSON{Msg: "unsupported"}}
+			c.Health = 2
+			switch {
+			case strings.HasPrefix(c.Transport, "split:2|"):
+				tcp.DurationMs = 100
+			case strings.HasPrefix(c.Transport, "tlsfrag:3|"):
+				// Passing both protocols ranks first, even if slower
+				udp.Error = nil
+				c.Health = 1
+			case strings.HasPrefix(c.Transport, "split:5|"):
+			default:
+				tcp.Error = &errorJSON{Msg: "reset"}
+				c.Health = 3
+			}
+			c.TestReports = []*connectivityReport{tcp, udp}
+		}
+		return nil
+	}
+	ss := "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpwYXNz@example.com:443"
+	setting := &AppSettings{Configs: []Config{{ID: "ss", Transport: ss}}}
+
+	working, err := AutoTune(setting, "ss")
+	require.NoError(t, err)
+	assert.Equal(t, autoTuneCandidates(ss), tested)
+	require.Len(t, working, 3)
+	assert.Equal(t, "tlsfrag:3|"+ss, working[0].Transport)
+	assert.Equal(t, "split:2|"+ss, working[1].Transport)
+	assert.Equal(t, "split:5|"+ss, working[2].Transport)
+
+	_, err = AutoTune(setting, "removed")
+	assert.Error(t, err)
+}
@@ -4,10 +4,13 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
+	"log"
+	"sort"
 	"strings"
+	"unicode/utf8"
+
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+	"github.com/Jigsaw-Code/outline-sdk/x/config"
 )
 
 // FormatA struct for the first JSON format
@@ -29,59 +32,139 @@ type SIP008Config struct {
 	Servers         []ServerInfo           `json:"servers"`
 	BytesUsed       uint64                 `json:"bytes_used,omitempty"`
 	BytesRemaining  uint64                 `json:"bytes_remaining,omitempty"`
-	AdditionalProps map[string]interface{} // For custom fields
+	AdditionalProps map[string]interface{} `json:"-"` // For custom fields
 }
 
-func parseDynamicConfig(data []byte) ([]string, error) {
-	//Parse if simple JSON format
-	server, err := parseSingleJSON(data)
-	if err == nil {
-		return []string{server}, nil
+// configFormat is the format of a config list, as detected on import.
+type configFormat string
+
+const (
+	formatOutlineJSON  configFormat = "Outline JSON"
+	formatSIP008       configFormat = "SIP008 JSON"
+	formatBase64Std    configFormat = "base64"
+	formatBase64StdRaw configFormat = "base64 without padding"
+	formatBase64URL    configFormat = "base64url"
+	formatBase64URLRaw configFormat = "base64url without padding"
+	formatPlainList    configFormat = "plain list"
+	formatClashYAML    configFormat = "Clash YAML"
+	formatSingBox      configFormat = "sing-box JSON"
+	formatV2Ray        configFormat = "v2ray JSON"
+)
+
+// maxRejectedInSummary limits the rejected lines listed in an import summary.
+const maxRejectedInSummary = 20
+
+// importResult is the outcome of importing a config list.
+type importResult struct {
+	Format   configFormat
+	Accepted []string
+	Rejected []rejectedLine
+	// Skipped counts the entries of unsupported proxy types by type.
+	Skipped map[string]int
+}
+
+// rejectedLine is a line or server of a config list that was not imported.
+type rejectedLine struct {
+	// Item is "line N" for config lists and "server N" for SIP008 documents.
+	Item   string
+	Reason string
+}
+
+func (r *importResult) reject(item string, err error) {
+	r.Rejected = append(r.Rejected, rejectedLine{Item: item, Reason: err.Error()})
+}
+
+func (r *importResult) skip(proxyType string) {
+	if r.Skipped == nil {
+		r.Skipped = make(map[string]int)
 	}
-	// Parse if SIP008 JSON format
-	servers, err := parseSIP008(data)
-	if err == nil {
-		return servers, nil
-	} else {
-		fmt.Println("parseSIP008 error:", err)
+	if proxyType == "" {
+		proxyType = "unknown"
 	}
-	// Parse if CSV format
-	servers, err = parseBase64URLLine(data)
-	if err == nil {
-		return servers, nil
-	} else {
-		fmt.Println("parseBase64URLLine error:", err)
+	r.Skipped[proxyType]++
+}
+
+// Summary describes the detected format, accepted configs and rejected lines for the user.
+func (r *importResult) Summary() string {
+	lines := []string{
+		fmt.Sprintf("Format: %v", r.Format),
+		fmt.Sprintf("Accepted: %d configs", len(r.Accepted)),
 	}
-	servers, err = parseCSVformat(data)
-	if err == nil {
-		return servers, nil
-	} else {
-		fmt.Println("parseCSVformat error:", err)
+	if len(r.Skipped) > 0 {
+		var types []string
+		for proxyType := range r.Skipped {
+			types = append(types, proxyType)
+		}
+		sort.Strings(types)
+		var skipped []string
+		for _, proxyType := range types {
+			skipped = append(skipped, fmt.Sprintf("%d %v", r.Skipped[proxyType], proxyType))
+		}
+		lines = append(lines, "Skipped unsupported: "+strings.Join(skipped, ", "))
+	}
+	if len(r.Rejected) > 0 {
+		lines = append(lines, fmt.Sprintf("Rejected: %d", len(r.Rejected)))
+		for i, rejected := range r.Rejected {
+			if i == maxRejectedInSummary {
+				lines = append(lines, fmt.Sprintf("... and %d more", len(r.Rejected)-i))
+				break
+			}
+			lines = append(lines, fmt.Sprintf("%v: %v", rejected.Item, rejected.Reason))
+		}
 	}
-	return []string{}, fmt.Errorf("unknown format")
-	// parse
+	return strings.Join(lines, "\n")
 }
 
-func getDynamicConfig(url string) ([]string, error) {
-	response, err := http.Get(url)
-	if err != nil {
-		fmt.Println("Error fetching URL:", err)
-		return []string{}, err
+// parseDynamicConfig detects the format of a config list and validates every
+// config in it. It fails if the data is not a config list or has no valid config.
+func parseDynamicConfig(data []byte) (*importResult, error) {
+	text := strings.TrimSpace(string(data))
+	var result *importResult
+	switch {
+	case text == "":
+		return nil, fmt.Errorf("empty config list")
+	case strings.HasPrefix(text, "{"):
+		var err error
+		if result, err = parseJSONConfig([]byte(text)); err != nil {
+			return nil, err
+		}
+	case strings.HasPrefix(text, "<"):
+		return nil, fmt.Errorf("not a config list, the document looks like an HTML page")
+	case isClashYAML(text):
+		var err error
+		if result, err = parseClashYAML([]byte(text)); err != nil {
+			return nil, err
+		}
+	default:
+		result = &importResult{Format: formatPlainList}
+		// Plain lists contain ':' and never decode as base64
+		if decoded, format, err := decodeBase64(text); err == nil && utf8.Valid(decoded) {
+			result.Format = format
+			text = string(decoded)
+		}
+		parseCSVformat(text, result)
+	}
+	if len(result.Accepted) == 0 {
+		return result, fmt.Errorf("no valid configs in %v", result.Format)
 	}
-	defer response.Body.Close()
+	return result, nil
+}
 
-	body, err := io.ReadAll(response.Body)
+// getDynamicConfig fetches and validates the config list at url.
+func getDynamicConfig(url string, f *fetcher) (*importResult, error) {
+	response, err := f.get(url, nil, maxSubscriptionSize)
 	if err != nil {
-		fmt.Println("Error reading response:", err)
-		return []string{}, err
+		log.Println("Error fetching URL:", err)
+		return nil, err
 	}
 
-	conf, err := parseDynamicConfig(body)
+	result, err := parseDynamicConfig(response.Body)
 	if err != nil {
-		fmt.Println("Error detecting format:", err)
-		return []string{}, err
+		log.Println("Error importing config list:", err)
+		return nil, err
 	}
-	return conf, nil
+	log.Printf("Imported %d configs from a %v, rejected %d", len(result.Accepted), result.Format, len(result.Rejected))
+	return result, nil
 }
 
 func parseSingleJSON(data []byte) (string, error) {
@@ -94,83 +177,122 @@ func parseSingleJSON(data []byte) (string, error) {
 	return makeShadowsocksURLfromJSON(&config)
 }
 
-func parseSIP008(data []byte) ([]string, error) {
+// parseJSONConfig parses an Outline JSON access key, a SIP008 document or the
+// outbounds of a sing-box or v2ray config, telling them apart by their keys.
+func parseJSONConfig(data []byte) (*importResult, error) {
+	var probe struct {
+		Servers   json.RawMessage   `json:"servers"`
+		Outbounds []json.RawMessage `json:"outbounds"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if probe.Outbounds != nil {
+		return parseOutbounds(probe.Outbounds)
+	}
+	if probe.Servers != nil {
+		return parseSIP008(data)
+	}
+	result := &importResult{Format: formatOutlineJSON}
+	server, err := parseSingleJSON(data)
+	if err == nil {
+		err = validateTransport(server)
+	}
+	if err != nil {
+		result.reject("server 1", err)
+	} else {
+		result.Accepted = append(result.Accepted, server)
+	}
+	return result, nil
+}
+
+func parseSIP008(data []byte) (*importResult, error) {
 	//Parse if SIP008 JSON format
 	var config SIP008Config
 	err := json.Unmarshal(data, &config)
 	if err != nil {
-		return []string{}, err
-	}
-	if config.Version == 1 {
-		var result []string
-		for _, server := range config.Servers {
-			configURL, err := makeShadowsocksURLfromJSON(&server)
-			if err != nil {
-				return []string{}, err
-			}
-			result = append(result, configURL)
+		return nil, fmt.Errorf("invalid SIP008 document: %w", err)
+	}
+	if config.Version != 1 {
+		return nil, fmt.Errorf("unknown SIP008 version: %d", config.Version)
+	}
+	result := &importResult{Format: formatSIP008}
+	for i, server := range config.Servers {
+		configURL, err := makeShadowsocksURLfromJSON(&server)
+		if err == nil {
+			err = validateTransport(configURL)
+		}
+		if err != nil {
+			result.reject(fmt.Sprintf("server %d", i+1), err)
+			continue
 		}
-		return result, nil
+		result.Accepted = append(result.Accepted, configURL)
 	}
-	return []string{}, fmt.Errorf("unknown SIP008 version: %d", config.Version)
+	return result, nil
 }
 
-func parseCSVformat(data []byte) ([]string, error) {
-	// fmt.Println("Printing response string:")
-	str := string(data)
-	configs := strings.Split(str, "\n")
-	fmt.Println("Printing response string:")
-	fmt.Println(configs)
-	// check of each line contains a valid URL
-	var validConfigs []string
-	for _, config := range configs {
-		// Ignore blank lines
-		if config == "" {
+// parseCSVformat validates the config of every non-blank line of text.
+func parseCSVformat(text string, result *importResult) {
+	for i, line := range strings.Split(text, "\n") {
+		// Ignore blank lines and the CR of CRLF line endings
+		line = strings.TrimSpace(line)
+		if line == "" {
 			continue
 		}
-		u, err := url.Parse(config)
-		// ignore invalid URLs
-		if err != nil {
+		if err := validateTransport(line); err != nil {
+			result.reject(fmt.Sprintf("line %d", i+1), err)
 			continue
 		}
-		fmt.Println("scheme:", u.Scheme)
-		// ignore non-URLs
-		if u.Scheme == "" {
-			continue
-		}
-		validConfigs = append(validConfigs, config)
+		result.Accepted = append(result.Accepted, line)
 	}
-	return validConfigs, nil
 }
 
-// https://www.v2fly.org/en_US/v5/config/service/subscription.html#subscription-container
-func parseBase64URLLine(data []byte) ([]string, error) {
-	decoded, err := base64.URLEncoding.WithPadding(base64.NoPadding).DecodeString(string(data))
-	if err != nil {
-		return []string{}, err
+// validateTransport checks a config with the outline-sdk config parser. The
+// fragment holds the config name and is ignored. Errors never contain secrets.
+func validateTransport(transportConfig string) error {
+	transportConfig, _ = splitTransportName(transportConfig)
+	if strings.Contains(transportConfig, "|") {
+		_, err := parseTransportChain(transportConfig)
+		return err
 	}
-	return parseCSVformat(decoded)
-}
-
-func makeShadowsocksURLfromJSON(config *ServerInfo) (string, error) {
-	if config.ServerPort == 0 {
-		return "", fmt.Errorf("missing server port")
+	scheme, _, found := strings.Cut(transportConfig, ":")
+	if !found || scheme == "" || strings.ContainsAny(scheme, " <>\"'/") {
+		return fmt.Errorf("not a config URL")
 	}
-	if config.Method == "" {
-		return "", fmt.Errorf("missing method")
+	if _, err := config.NewDefaultConfigParser().WrapStreamDialer(&transport.TCPDialer{}, transportConfig); err != nil {
+		return fmt.Errorf("invalid %v config", scheme)
 	}
-	if config.Password == "" {
-		return "", fmt.Errorf("missing password")
+	return nil
+}
+
+// decodeBase64 decodes any of the four base64 variants, ignoring whitespace and
+// line breaks, and returns which variant it detected.
+func decodeBase64(s string) ([]byte, configFormat, error) {
+	s = strings.Join(strings.Fields(s), "")
+	if s == "" {
+		return nil, "", fmt.Errorf("empty base64 data")
 	}
-	if config.Server == "" {
-		return "", fmt.Errorf("missing server")
+	urlSafe := strings.ContainsAny(s, "-_")
+	if urlSafe && strings.ContainsAny(s, "+/") {
+		return nil, "", fmt.Errorf("mixed base64 alphabets")
 	}
-	configURL := "ss://" + config.Method + ":" + config.Password + "@" + config.Server + ":" + fmt.Sprint(config.ServerPort)
-	if config.Prefix != "" {
-		configURL += "/?prefix=" + url.QueryEscape(config.Prefix)
+	// Data whose length is a multiple of 4 needs no padding, so it decodes either way
+	padded := strings.HasSuffix(s, "=") || len(s)%4 == 0
+	var encoding *base64.Encoding
+	var format configFormat
+	switch {
+	case urlSafe && padded:
+		encoding, format = base64.URLEncoding, formatBase64URL
+	case urlSafe:
+		encoding, format = base64.RawURLEncoding, formatBase64URLRaw
+	case padded:
+		encoding, format = base64.StdEncoding, formatBase64Std
+	default:
+		encoding, format = base64.RawStdEncoding, formatBase64StdRaw
 	}
-	if config.Plugin != "" {
-		configURL += "&plugin=" + url.QueryEscape(config.Plugin)
+	decoded, err := encoding.DecodeString(s)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid %v data: %w", format, err)
 	}
-	return configURL, nil
+	return decoded, format, nil
 }
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// subscriptionFixtureLines are the configs encoded in every testdata/subscriptions fixture.
+var subscriptionFixtureLines = []string{
+	"ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpzZWNyZXQ@a.example.com:443#Tōkyō ~ 1",
+	"ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpzZWNyZXQ@b.example.com:8388/?prefix=POST%20#Frankfurt?",
+	"socks5://proxy.example.com:1080",
+}
+
+// readFixture returns the contents of the fixture testdata/<dir>/<name>.
+func readFixture(t *testing.T, dir, name string) []byte {
+	data, err := os.ReadFile(filepath.Join("testdata", dir, name))
+	require.NoError(t, err)
+	return data
+}
+
+func TestParseDynamicConfigFixtures(t *testing.T) {
+	tests := []struct {
+		file   string
+		format configFormat
+	}{
+		{"std.txt", formatBase64Std},
+		{"std_wrapped_crlf.txt", formatBase64Std},
+		{"std_nopad.txt", formatBase64StdRaw},
+		{"url.txt", formatBase64URL},
+		{"url_nopad.txt", formatBase64URLRaw},
+		{"plain_crlf.txt", formatPlainList},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			result, err := parseDynamicConfig(readFixture(t, "subscriptions", tt.file))
+			require.NoError(t, err)
+			assert.Equal(t, tt.format, result.Format)
+			assert.Equal(t, subscriptionFixtureLines, result.Accepted)
+			assert.Empty(t, result.Rejected)
+		})
+	}
+}
+
+func TestDecodeBase64(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   string
+		format configFormat
+	}{
+		{"aGk/Pz4+", "hi??>>", formatBase64Std},
+		{"aGk_Pz4-", "hi??>>", formatBase64URL},
+		{"aGk/Pz4", "hi??>", formatBase64StdRaw},
+		{"aGk_Pz4", "hi??>", formatBase64URLRaw},
+		{"aGk/Pz4=", "hi??>", formatBase64Std},
+		{" aGk_\r\nPz4=\n", "hi??>", formatBase64URL},
+	}
+	for _, tt := range tests {
+		decoded, format, err := decodeBase64(tt.input)
+		require.NoError(t, err, tt.input)
+		assert.Equal(t, tt.want, string(decoded), tt.input)
+		assert.Equal(t, tt.format, format, tt.input)
+	}
+
+	for _, input := range []string{"", " \r\n", "aGk/Pz4-", "a", "not base64!"} {
+		_, _, err := decodeBase64(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestParseDynamicConfigRejectsLines(t *testing.T) {
+	data := []byte(strings.Join([]string{
+		testSSKey + "#Good",
+		"https://example.com/index.html",
+		"ss://notbase64@example.com:443",
+		"",
+		"hello world",
+		"split:3|" + testSSKey,
+	}, "\r\n"))
+	result, err := parseDynamicConfig(data)
+	require.NoError(t, err)
+	assert.Equal(t, formatPlainList, result.Format)
+	assert.Equal(t, []string{testSSKey + "#Good", "split:3|" + testSSKey}, result.Accepted)
+	assert.Equal(t, []rejectedLine{
+		{Item: "line 2", Reason: "invalid https config"},
+		{Item: "line 3", Reason: "invalid ss config"},
+		{Item: "line 5", Reason: "not a config URL"},
+	}, result.Rejected)
+	summary := result.Summary()
+	assert.Contains(t, summary, "Accepted: 2 configs")
+	assert.Contains(t, summary, "line 3: invalid ss config")
+	assert.NotContains(t, summary, "notbase64")
+}
+
+func TestParseDynamicConfigNotAList(t *testing.T) {
+	for _, data := range []string{
+		"<!DOCTYPE html><html><body><a href=\"ss://x\">link</a></body></html>",
+		"   \r\n",
+		"{\"error\": ",
+		"https://example.com/a\nhttps://example.com/b",
+		"Service Unavailable",
+	} {
+		_, err := parseDynamicConfig([]byte(data))
+		assert.Error(t, err, data)
+	}
+}
+
+func TestParseDynamicConfigJSON(t *testing.T) {
+	result, err := parseDynamicConfig([]byte(`{"server": "example.com", "server_port": 8388, "password": "secret", "method": "chacha20-ietf-poly1305"}`))
+	require.NoError(t, err)
+	assert.Equal(t, formatOutlineJSON, result.Format)
+	assert.Len(t, result.Accepted, 1)
+
+	result, err = parseDynamicConfig([]byte(`{"version": 1, "servers": [
+		{"server": "example.com", "server_port": 8388, "password": "secret", "method": "chacha20-ietf-poly1305"},
+		{"server": "example.org", "server_port": 8388, "method": "chacha20-ietf-poly1305"},
+		{"server": "example.net", "server_port": 8388, "password": "secret", "method": "rot13"}
+	]}`))
+	require.NoError(t, err)
+	assert.Equal(t, formatSIP008, result.Format)
+	assert.Len(t, result.Accepted, 1)
+	assert.Equal(t, []rejectedLine{
+		{Item: "server 2", Reason: "missing password"},
+		{Item: "server 3", Reason: "invalid ss config"},
+	}, result.Rejected)
+
+	_, err = parseDynamicConfig([]byte(`{"version": 2, "servers": []}`))
+	assert.ErrorContains(t, err, "unknown SIP008 version")
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// clashProxiesKey matches the top-level "proxies:" key of a Clash config.
+var clashProxiesKey = regexp.MustCompile(`(?m)^proxies\s*:`)
+
+// clashProxy is an entry of the proxies list of a Clash config.
+// https://wiki.metacubex.one/en/config/proxies/
+type clashProxy struct {
+	Name           string `yaml:"name"`
+	Type           string `yaml:"type"`
+	Server         string `yaml:"server"`
+	Port           int    `yaml:"port"`
+	Cipher         string `yaml:"cipher"`
+	Password       string `yaml:"password"`
+	Username       string `yaml:"username"`
+	Plugin         string `yaml:"plugin"`
+	TLS            bool   `yaml:"tls"`
+	SkipCertVerify bool   `yaml:"skip-cert-verify"`
+}
+
+// isClashYAML reports whether text looks like a Clash config with a proxies list.
+func isClashYAML(text string) bool {
+	return clashProxiesKey.MatchString(text)
+}
+
+// parseClashYAML imports the ss and socks5 entries of a Clash proxies list.
+// Other proxy types are counted as skipped.
+func parseClashYAML(data []byte) (*importResult, error) {
+	var document struct {
+		Proxies []yaml.Node `yaml:"proxies"`
+	}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("invalid Clash YAML: %w", err)
+	}
+	result := &importResult{Format: formatClashYAML}
+	for i, node := range document.Proxies {
+		item := fmt.Sprintf("proxy %d", i+1)
+		var proxy clashProxy
+		if err := node.Decode(&proxy); err != nil {
+			result.reject(item, fmt.Errorf("invalid proxy entry"))
+			continue
+		}
+		var configURL string
+		var err error
+		switch proxy.Type {
+		case "ss":
+			configURL, err = proxy.shadowsocksURL()
+		case "socks5":
+			configURL, err = proxy.socks5URL()
+		default:
+			result.skip(proxy.Type)
+			continue
+		}
+		if err == nil {
+			err = validateTransport(configURL)
+		}
+		if err != nil {
+			result.reject(item, err)
+			continue
+		}
+		result.Accepted = append(result.Accepted, configURL)
+	}
+	return result, nil
+}
+
+// shadowsocksURL maps a Clash ss proxy to a SIP002 URL. Proxies with a plugin,
+// such as obfs or v2ray-plugin, are rejected, as outline-sdk dials Shadowsocks
+// without plugins and would fail to reach them.
+func (p *clashProxy) shadowsocksURL() (string, error) {
+	if p.Plugin != "" {
+		return "", fmt.Errorf("ss plugin %v is not supported", p.Plugin)
+	}
+	info := ServerInfo{
+		Remarks:    p.Name,
+		Server:     p.Server,
+		ServerPort: p.Port,
+		Method:     p.Cipher,
+		Password:   p.Password,
+	}
+	return makeShadowsocksURLfromJSON(&info)
+}
+
+// socks5URL maps a Clash socks5 proxy to a socks5:// URL, dialed over TLS if the proxy uses it.
+func (p *clashProxy) socks5URL() (string, error) {
+	if p.SkipCertVerify {
+		return "", fmt.Errorf("skip-cert-verify is not supported")
+	}
+	configURL, err := makeSocks5URL(p.Server, p.Port, p.Username, p.Password, p.Name)
+	if err != nil {
+		return "", err
+	}
+	if p.TLS {
+		configURL = "tls|" + configURL
+	}
+	return configURL, nil
+}
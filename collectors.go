@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-sdk/x/report"
+)
+
+// Collector destination kinds.
+const (
+	collectorHTTPS  = "https"
+	collectorFile   = "file"
+	collectorUnix   = "unix"
+	collectorStdout = "stdout"
+)
+
+// defaultMaxFileBytes is the size at which a file collector rotates its file.
+const defaultMaxFileBytes = 10 << 20
+
+// CollectorDestination is one place reports are sent to, with its own retry and sampling settings.
+type CollectorDestination struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	// Target is the collector URL, the JSONL file path or the Unix socket path.
+	Target          string  `json:"target"`
+	MaxRetry        int     `json:"maxRetry"`
+	RetryDelayMs    int64   `json:"retryDelayMs"`
+	SuccessFraction float64 `json:"successFraction"`
+	FailureFraction float64 `json:"failureFraction"`
+	// MaxFileBytes is the rotation size of file collectors.
+	MaxFileBytes int64 `json:"maxFileBytes,omitempty"`
+}
+
+// reporterDestination is the name of the destination of ReporterURL, where
+// reports went before collector destinations were added.
+const reporterDestination = "reporter"
+
+// deliveryResult records the outcome of sending a report to one destination.
+type deliveryResult struct {
+	Destination string    `json:"destination"`
+	Collected   bool      `json:"collected"`
+	Time        time.Time `json:"time"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// reportSettings are the settings that decide whether, where and how reports
+// are sent. The settings page changes them while reports are sent, so reports
+// are sent with a snapshot taken under settingsMutex.
+type reportSettings struct {
+	Privacy      ReportPrivacy
+	ViaTunnel    bool
+	Destinations []CollectorDestination
+}
+
+// reportSettings returns a snapshot of the report settings.
+func (s *AppSettings) reportSettings() reportSettings {
+	settingsMutex.RLock()
+	defer settingsMutex.RUnlock()
+	return reportSettings{Privacy: s.ReportPrivacy, ViaTunnel: s.ReportViaTunnel, Destinations: s.collectorDestinations()}
+}
+
+// collectorDestinations returns a copy of the configured destinations. Without
+// any, reports go to ReporterURL if it is set, or to stdout otherwise.
+// The caller holds settingsMutex.
+func (s *AppSettings) collectorDestinations() []CollectorDestination {
+	if len(s.Collectors) > 0 {
+		return append([]CollectorDestination(nil), s.Collectors...)
+	}
+	if strings.TrimSpace(s.ReporterURL) != "" {
+		return []CollectorDestination{{
+			Name: reporterDestination, Kind: collectorHTTPS, Target: s.ReporterURL,
+			MaxRetry: 3, RetryDelayMs: 1000, SuccessFraction: 1, FailureFraction: 1,
+		}}
+	}
+	return []CollectorDestination{{Name: "stdout", Kind: collectorStdout, SuccessFraction: 1, FailureFraction: 1}}
+}
+
+// collectorsSnapshot returns a copy of the configured destinations.
+func (s *AppSettings) collectorsSnapshot() []CollectorDestination {
+	settingsMutex.RLock()
+	defer settingsMutex.RUnlock()
+	return append([]CollectorDestination(nil), s.Collectors...)
+}
+
+// removeCollector removes the destination with the given name.
+func (s *AppSettings) removeCollector(name string) {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	s.Collectors = slices.DeleteFunc(s.Collectors, func(d CollectorDestination) bool { return d.Name == name })
+}
+
+// newCollector builds the collector chain of a destination. The user's report
+// fractions scale the destination's own sampling fractions.
+func (d CollectorDestination) newCollector(settings reportSettings) (report.Collector, error) {
+	var c report.Collector
+	switch d.Kind {
+	case collectorHTTPS:
+		collectorURL, err := url.Parse(strings.TrimSpace(d.Target))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse collector URL: %w", err)
+		}
+		c = &report.RemoteCollector{CollectorURL: collectorURL, HttpClient: reportHTTPClient(settings.ViaTunnel)}
+	case collectorFile:
+		maxBytes := d.MaxFileBytes
+		if maxBytes <= 0 {
+			maxBytes = defaultMaxFileBytes
+		}
+		c = &rotatingFileCollector{Path: d.Target, MaxBytes: maxBytes}
+	case collectorUnix:
+		c = &unixSocketCollector{Path: d.Target}
+	case collectorStdout:
+		c = &report.WriteCollector{Writer: os.Stdout}
+	default:
+		return nil, fmt.Errorf("unknown collector kind: %v", d.Kind)
+	}
+	if d.MaxRetry > 0 {
+		c = &report.RetryCollector{
+			Collector:    c,
+			MaxRetry:     d.MaxRetry,
+			InitialDelay: time.Duration(d.RetryDelayMs) * time.Millisecond,
+		}
+	}
+	return &report.SamplingCollector{
+		Collector:       c,
+		SuccessFraction: d.SuccessFraction * settings.Privacy.SuccessFraction,
+		FailureFraction: d.FailureFraction * settings.Privacy.FailureFraction,
+	}, nil
+}
+
+// rotatingFileCollector appends reports as JSON lines to a file, moving it
+// to Path+".1" once it grows past MaxBytes.
+type rotatingFileCollector struct {
+	Path     string
+	MaxBytes int64
+}
+
+// fileCollectorMutex serializes writes and rotation of all file collectors.
+var fileCollectorMutex sync.Mutex
+
+func (c *rotatingFileCollector) Collect(ctx context.Context, r report.Report) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	fileCollectorMutex.Lock()
+	defer fileCollectorMutex.Unlock()
+	if info, err := os.Stat(c.Path); err == nil && info.Size()+int64(len(data))+1 > c.MaxBytes {
+		if err := os.Rename(c.Path, c.Path+".1"); err != nil {
+			return fmt.Errorf("failed to rotate report file: %w", err)
+		}
+	}
+	f, err := os.OpenFile(c.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// unixSocketCollector writes each report as a JSON line to a local Unix socket.
+type unixSocketCollector struct {
+	Path string
+}
+
+func (c *unixSocketCollector) Collect(ctx context.Context, r report.Report) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", c.Path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write(append(data, '\n'))
+	return err
+}
+
+// collectReport fans a redacted copy of the report out to every destination it
+// was not delivered to yet, and records each destination's result on the report.
+// The deliveries are recorded under settingsMutex, as the report may be stored in a config.
+func collectReport(r *connectivityReport, settings reportSettings) error {
+	settingsMutex.RLock()
+	redacted := redactReport(r, settings.Privacy)
+	settingsMutex.RUnlock()
+	var errs []error
+	for _, d := range settings.Destinations {
+		settingsMutex.RLock()
+		delivered := r.deliveredTo(d.Name)
+		settingsMutex.RUnlock()
+		if delivered {
+			continue
+		}
+		collector, err := d.newCollector(settings)
+		if err == nil {
+			debugLog.Printf("Collecting report to %v", d.Name)
+			err = collector.Collect(context.Background(), redacted)
+		}
+		settingsMutex.Lock()
+		r.setDelivery(d.Name, err)
+		settingsMutex.Unlock()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", d.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deliveredTo reports whether the report was collected by the named destination.
+func (r *connectivityReport) deliveredTo(name string) bool {
+	for _, d := range r.Deliveries {
+		if d.Destination == name {
+			return d.Collected
+		}
+	}
+	return false
+}
+
+// setDelivery records the result of sending the report to the named destination.
+func (r *connectivityReport) setDelivery(name string, err error) {
+	result := deliveryResult{Destination: name, Collected: err == nil, Time: time.Now().UTC().Truncate(time.Second)}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	for i := range r.Deliveries {
+		if r.Deliveries[i].Destination == name {
+			r.Deliveries[i] = result
+			return
+		}
+	}
+	r.Deliveries = append(r.Deliveries, result)
+}
+
+// UnmarshalJSON reads reports of every schema version. Before version 3, a
+// report collected by ReporterURL was marked "collected", which becomes a
+// delivery to its destination so that the report is not sent again.
+func (r *connectivityReport) UnmarshalJSON(data []byte) error {
+	type report connectivityReport
+	legacy := struct {
+		*report
+		Collected bool `json:"collected"`
+	}{report: (*report)(r)}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+	if legacy.Collected && r.SchemaVersion < 3 && !r.deliveredTo(reporterDestination) {
+		r.Deliveries = append(r.Deliveries, deliveryResult{Destination: reporterDestination, Collected: true, Time: r.Time})
+	}
+	return nil
+}
+
+// IsCollected reports whether every destination the report was sent to collected it.
+func (r *connectivityReport) IsCollected() bool {
+	if len(r.Deliveries) == 0 {
+		return false
+	}
+	for _, d := range r.Deliveries {
+		if !d.Collected {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectReportFansOut(t *testing.T) {
+	dir := t.TempDir()
+	socketPath := filepath.Join(dir, "collector.sock")
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	defer listener.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		received <- line
+	}()
+
+	filePath := filepath.Join(dir, "reports.jsonl")
+	setting := &AppSettings{
+		ReportPrivacy: defaultReportPrivacy,
+		Collectors: []CollectorDestination{
+			{Name: "file", Kind: collectorFile, Target: filePath, SuccessFraction: 1, FailureFraction: 1},
+			{Name: "socket", Kind: collectorUnix, Target: socketPath, SuccessFraction: 1, FailureFraction: 1},
+			{Name: "missing", Kind: collectorUnix, Target: filepath.Join(dir, "missing.sock"), SuccessFraction: 1, FailureFraction: 1},
+		},
+	}
+	r := &connectivityReport{Proto: "tcp", Transport: "ss://REDACTED@example.com:443", Time: time.Unix(1700000000, 0)}
+
+	err = collectReport(r, setting.reportSettings())
+	assert.ErrorContains(t, err, "missing")
+	require.Len(t, r.Deliveries, 3)
+	assert.True(t, r.deliveredTo("file"))
+	assert.True(t, r.deliveredTo("socket"))
+	assert.False(t, r.deliveredTo("missing"))
+	assert.False(t, r.IsCollected())
+
+	var sent connectivityReport
+	require.NoError(t, json.Unmarshal([]byte(<-received), &sent))
+	assert.Equal(t, "tcp", sent.Proto)
+	assert.Empty(t, sent.Deliveries)
+
+	// A retry only goes to the destination that failed
+	setting.Collectors = setting.Collectors[:2]
+	require.NoError(t, collectReport(r, setting.reportSettings()))
+	data, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(data), "\n"))
+}
+
+func TestReportSettingsSnapshot(t *testing.T) {
+	setting := &AppSettings{
+		ReportPrivacy: ReportPrivacy{Consent: true, SuccessFraction: 1, FailureFraction: 0.5},
+		Collectors:    []CollectorDestination{{Name: "file", Kind: collectorFile, Target: "reports.jsonl"}},
+	}
+	settings := setting.reportSettings()
+
+	// Changes on the settings page do not reach reports being sent
+	setting.Collectors[0].Name = "renamed"
+	setting.removeCollector("renamed")
+	setting.ReportPrivacy.Consent = false
+	assert.Equal(t, []CollectorDestination{{Name: "file", Kind: collectorFile, Target: "reports.jsonl"}}, settings.Destinations)
+	assert.True(t, settings.Privacy.Consent)
+	assert.Empty(t, setting.collectorsSnapshot())
+}
+
+func TestRotatingFileCollector(t *testing.T) {
+	r := &connectivityReport{Proto: "udp", Transport: "ss://REDACTED@example.com:443"}
+	line, err := json.Marshal(r)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "reports.jsonl")
+	// Room for two reports per file
+	c := &rotatingFileCollector{Path: path, MaxBytes: int64(2 * (len(line) + 1))}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, c.Collect(context.Background(), r))
+	}
+	rotated, err := os.ReadFile(path + ".1")
+	require.NoError(t, err)
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(rotated), "\n"))
+	assert.Equal(t, 1, strings.Count(string(current), "\n"))
+}
+
+func TestUnmarshalLegacyCollectedReport(t *testing.T) {
+	v2 := `{"schema_version": 2, "resolver": "8.8.8.8", "proto": "tcp", "transport": "ss://host:443",
+		"time": "2024-01-02T03:04:05Z", "duration_ms": 120, "error": null, "collected": true}`
+	var r connectivityReport
+	require.NoError(t, json.Unmarshal([]byte(v2), &r))
+	assert.Equal(t, "tcp", r.Proto)
+	assert.Equal(t, int64(120), r.DurationMs)
+	assert.True(t, r.IsCollected())
+	assert.True(t, r.deliveredTo(reporterDestination))
+
+	// The collected report is not sent to the reporter again
+	sent := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { sent++ }))
+	defer server.Close()
+	require.NoError(t, collectReport(&r, (&AppSettings{ReporterURL: server.URL, ReportPrivacy: defaultReportPrivacy}).reportSettings()))
+	assert.Equal(t, 0, sent)
+
+	// Uncollected v1 reports, without a schema version, are still pending
+	var v1 connectivityReport
+	require.NoError(t, json.Unmarshal([]byte(`{"proto": "udp", "collected": false}`), &v1))
+	assert.False(t, v1.IsCollected())
+	assert.Empty(t, v1.Deliveries)
+
+	// Settings round-trip in the current schema
+	data, err := json.Marshal(&r)
+	require.NoError(t, err)
+	var again connectivityReport
+	require.NoError(t, json.Unmarshal(data, &again))
+	assert.Equal(t, r.Deliveries, again.Deliveries)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-sdk/dns"
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+	"github.com/Jigsaw-Code/outline-sdk/x/config"
+	"github.com/Jigsaw-Code/outline-sdk/x/connectivity"
+)
+
+// directRoute is the route name used for checks that bypass all configs.
+const directRoute = "direct"
+
+// routeCheck holds the outcome of the DNS and HTTPS checks of one domain over one route.
+type routeCheck struct {
+	Route    string `json:"route"`
+	DNSOK    bool   `json:"dns_ok"`
+	HTTPSOK  bool   `json:"https_ok"`
+	ErrorMsg string `json:"error,omitempty"`
+}
+
+// Reachable reports whether both checks passed.
+func (c routeCheck) Reachable() bool {
+	return c.DNSOK && c.HTTPSOK
+}
+
+// domainComparison compares reaching a domain directly and through each config.
+type domainComparison struct {
+	Domain  string       `json:"domain"`
+	Direct  routeCheck   `json:"direct"`
+	Tunnels []routeCheck `json:"tunnels"`
+}
+
+// Verdict summarizes the comparison, e.g. "blocked directly, reachable via X".
+func (d domainComparison) Verdict() string {
+	var via []string
+	for _, t := range d.Tunnels {
+		if t.Reachable() {
+			via = append(via, t.Route)
+		}
+	}
+	switch {
+	case d.Direct.Reachable():
+		return "reachable directly"
+	case len(via) > 0:
+		return "blocked directly, reachable via " + strings.Join(via, ", ")
+	case len(d.Tunnels) > 0:
+		return "blocked directly and through all configs"
+	default:
+		return "blocked directly"
+	}
+}
+
+// CompareDomains runs the DNS and HTTPS checks for each of the BlockedDomains,
+// once with the plain dialers and once through each config.
+func CompareDomains(setting *AppSettings) []domainComparison {
+	resolverAddress := net.JoinHostPort(strings.TrimSpace(setting.ResolverHost), "53")
+	configs := setting.configsSnapshot()
+	results := make([]domainComparison, len(setting.BlockedDomains))
+	var wg sync.WaitGroup
+	for i, domain := range setting.BlockedDomains {
+		results[i].Domain = strings.TrimSpace(domain)
+		results[i].Tunnels = make([]routeCheck, len(configs))
+		wg.Add(1)
+		go func(r *domainComparison) {
+			defer wg.Done()
+			r.Direct = checkRoute(directRoute, &transport.TCPDialer{}, &transport.UDPDialer{}, resolverAddress, r.Domain)
+		}(&results[i])
+		for j := range configs {
+			wg.Add(1)
+			go func(r *domainComparison, j int) {
+				defer wg.Done()
+				r.Tunnels[j] = checkConfigRoute(&configs[j], resolverAddress, r.Domain)
+			}(&results[i], j)
+		}
+	}
+	wg.Wait()
+	return results
+}
+
+// checkConfigRoute builds the dialers of a config and checks the domain through them.
+func checkConfigRoute(cnf *Config, resolverAddress, domain string) routeCheck {
+	route := cnf.DisplayName()
+	configparser := config.NewDefaultConfigParser()
+	streamDialer, err := configparser.WrapStreamDialer(&transport.TCPDialer{}, cnf.Transport)
+	if err != nil {
+		return routeCheck{Route: route, ErrorMsg: err.Error()}
+	}
+	packetDialer, err := configparser.WrapPacketDialer(&transport.UDPDialer{}, cnf.Transport)
+	if err != nil {
+		// Not every transport supports UDP, so resolve over TCP instead.
+		log.Printf("Falling back to TCP resolver for %v: %v", route, err)
+		packetDialer = nil
+	}
+	return checkRoute(route, streamDialer, packetDialer, resolverAddress, domain)
+}
+
+// checkRoute resolves the domain and fetches it over HTTPS using the given dialers.
+// If packetDialer is nil, the DNS check runs over TCP.
+func checkRoute(route string, streamDialer transport.StreamDialer, packetDialer transport.PacketDialer, resolverAddress, domain string) routeCheck {
+	check := routeCheck{Route: route}
+	var resolver dns.Resolver
+	if packetDialer != nil {
+		resolver = dns.NewUDPResolver(packetDialer, resolverAddress)
+	} else {
+		resolver = dns.NewTCPResolver(streamDialer, resolverAddress)
+	}
+	result, err := connectivity.TestConnectivityWithResolver(context.Background(), resolver, domain)
+	switch {
+	case err != nil:
+		check.ErrorMsg = "dns: " + err.Error()
+	case result != nil:
+		check.ErrorMsg = "dns: " + result.Error()
+	default:
+		check.DNSOK = true
+	}
+	if err := checkHTTPS(streamDialer, domain); err != nil {
+		if check.ErrorMsg == "" {
+			check.ErrorMsg = "https: " + err.Error()
+		}
+	} else {
+		check.HTTPSOK = true
+	}
+	return check
+}
+
+// checkHTTPS sends a HEAD request to https://domain/ through the dialer.
+// Any HTTP response counts as success, since it proves the TLS handshake went through.
+func checkHTTPS(dialer transport.StreamDialer, domain string) error {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return dialer.DialStream(ctx, addr)
+			},
+		},
+		Timeout: 10 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Head("https://" + domain + "/")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// writeComparisonCSV writes one row per domain and route.
+func writeComparisonCSV(w io.Writer, results []domainComparison) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"domain", "route", "dns_ok", "https_ok", "error", "verdict"}); err != nil {
+		return err
+	}
+	for _, d := range results {
+		for _, c := range append([]routeCheck{d.Direct}, d.Tunnels...) {
+			record := []string{d.Domain, c.Route, fmt.Sprint(c.DNSOK), fmt.Sprint(c.HTTPSOK), c.ErrorMsg, d.Verdict()}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"log"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// makeComparePage creates the direct-vs-tunnel comparison page content
+func makeComparePage(ctx *AppContext, navChannel chan NavEvent) fyne.CanvasObject {
+	headerToolbarLeft := widget.NewToolbar(
+		widget.NewToolbarAction(theme.NavigateBackIcon(), func() {
+			navChannel <- NavEvent{TargetPage: "main"}
+		}),
+	)
+	headerToolbarRight := widget.NewToolbar()
+	header := makePageHeader("Compare", headerToolbarLeft, headerToolbarRight)
+
+	// results are those of the last comparison, handed over by showResults
+	// from the goroutine that runs it
+	var resultsMutex sync.Mutex
+	var results []domainComparison
+	currentResults := func() []domainComparison {
+		resultsMutex.Lock()
+		defer resultsMutex.Unlock()
+		return results
+	}
+
+	columns := []string{"Domain", "Direct", "Verdict"}
+	table := widget.NewTable(
+		func() (int, int) {
+			return len(currentResults()) + 1, len(columns)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.TableCellID, o fyne.CanvasObject) {
+			label := o.(*widget.Label)
+			if id.Row == 0 {
+				label.TextStyle = fyne.TextStyle{Bold: true}
+				label.SetText(columns[id.Col])
+				return
+			}
+			label.TextStyle = fyne.TextStyle{}
+			shown := currentResults()
+			if id.Row > len(shown) {
+				return
+			}
+			d := shown[id.Row-1]
+			switch id.Col {
+			case 0:
+				label.SetText(d.Domain)
+			case 1:
+				label.SetText(checkMark(d.Direct.DNSOK) + " DNS " + checkMark(d.Direct.HTTPSOK) + " HTTPS")
+			case 2:
+				label.SetText(d.Verdict())
+			}
+		},
+	)
+	table.SetColumnWidth(0, 160)
+	table.SetColumnWidth(1, 130)
+	table.SetColumnWidth(2, 400)
+	scrollContainer := container.NewStack(table)
+
+	status := widget.NewLabel("")
+	if len(ctx.Settings.BlockedDomains) == 0 {
+		status.SetText("Add domains to Blocked domains in settings")
+	}
+
+	var runButton *widget.Button
+	showResults := func(compared []domainComparison) {
+		resultsMutex.Lock()
+		results = compared
+		resultsMutex.Unlock()
+		table.Refresh()
+		status.SetText("")
+		runButton.Enable()
+	}
+	runButton = widget.NewButton("Compare", func() {
+		runButton.Disable()
+		status.SetText("Testing...")
+		go func() {
+			showResults(CompareDomains(ctx.Settings))
+		}()
+	})
+	runButton.Importance = widget.HighImportance
+
+	exportButton := widget.NewButton("Export CSV", func() {
+		dialog.ShowFileSave(func(writer fyne.URIWriteCloser, err error) {
+			if err != nil || writer == nil {
+				return
+			}
+			defer writer.Close()
+			if err := writeComparisonCSV(writer, currentResults()); err != nil {
+				log.Println("Error exporting comparison:", err)
+				dialog.ShowError(err, ctx.Window)
+			}
+		}, ctx.Window)
+	})
+
+	return container.NewBorder(
+		header,
+		container.NewVBox(status, container.NewGridWithColumns(2, exportButton, runButton)),
+		nil, nil,
+		scrollContainer,
+	)
+}
+
+// checkMark renders a check result as a symbol.
+func checkMark(ok bool) string {
+	if ok {
+		return "✅"
+	}
+	return "❌"
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComparisonVerdict(t *testing.T) {
+	reachable := routeCheck{DNSOK: true, HTTPSOK: true}
+	for expected, d := range map[string]domainComparison{
+		"reachable directly": {Direct: reachable, Tunnels: []routeCheck{{Route: "A"}}},
+		"blocked directly, reachable via A, C": {
+			Direct:  routeCheck{DNSOK: true},
+			Tunnels: []routeCheck{{Route: "A", DNSOK: true, HTTPSOK: true}, {Route: "B", HTTPSOK: true}, {Route: "C", DNSOK: true, HTTPSOK: true}},
+		},
+		"blocked directly and through all configs": {Tunnels: []routeCheck{{Route: "A", DNSOK: true}}},
+		"blocked directly":                         {Direct: routeCheck{HTTPSOK: true}},
+	} {
+		assert.Equal(t, expected, d.Verdict())
+	}
+}
+
+func TestWriteComparisonCSV(t *testing.T) {
+	results := []domainComparison{{
+		Domain:  "blocked.example.com",
+		Direct:  routeCheck{Route: directRoute, DNSOK: true, ErrorMsg: "https: connection reset"},
+		Tunnels: []routeCheck{{Route: "Server, \"EU\"", DNSOK: true, HTTPSOK: true}},
+	}}
+	var buf bytes.Buffer
+	require.NoError(t, writeComparisonCSV(&buf, results))
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	verdict := "blocked directly, reachable via Server, \"EU\""
+	assert.Equal(t, [][]string{
+		{"domain", "route", "dns_ok", "https_ok", "error", "verdict"},
+		{"blocked.example.com", "direct", "true", "false", "https: connection reset", verdict},
+		{"blocked.example.com", "Server, \"EU\"", "true", "true", "", verdict},
+	}, records)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// shadowsocksCiphers are the ciphers the Shadowsocks editor offers, by their
+// Shadowsocks names. outline-sdk also accepts their IETF names.
+var shadowsocksCiphers = []string{"chacha20-ietf-poly1305", "aes-256-gcm", "aes-192-gcm", "aes-128-gcm"}
+
+// splitShadowsocksHop parses the final hop of a transport as a Shadowsocks
+// server and returns the hops before it, such as "split:3|". ok is false if
+// the final hop is not a valid ss:// URL.
+func splitShadowsocksHop(transportConfig string) (chain string, server *ServerInfo, ok bool) {
+	transportConfig, _ = splitTransportName(strings.TrimSpace(transportConfig))
+	cut := strings.LastIndex(transportConfig, "|") + 1
+	last := strings.TrimSpace(transportConfig[cut:])
+	if !strings.HasPrefix(strings.ToLower(last), "ss://") {
+		return "", nil, false
+	}
+	server, err := parseShadowsocksURL(last)
+	if err != nil {
+		return "", nil, false
+	}
+	server.Remarks = ""
+	return transportConfig[:cut], server, true
+}
+
+// joinShadowsocksHop puts the server as a SIP002 URL after the hops of chain.
+func joinShadowsocksHop(chain string, server *ServerInfo) (string, error) {
+	withoutName := *server
+	withoutName.Remarks, withoutName.ID = "", ""
+	hop, err := makeShadowsocksURLfromJSON(&withoutName)
+	if err != nil {
+		return "", err
+	}
+	return chain + hop, nil
+}
+
+// parseServerPort parses the port of the Shadowsocks editor.
+func parseServerPort(port string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(port))
+	if err != nil || n < 1 || n > 65535 {
+		return 0, fmt.Errorf("invalid port")
+	}
+	return n, nil
+}
+
+// escapePrefix shows a Shadowsocks prefix, which is often binary, with its
+// unprintable bytes percent-encoded. unescapePrefix reverses it.
+func escapePrefix(prefix string) string {
+	return url.PathEscape(prefix)
+}
+
+func unescapePrefix(escaped string) (string, error) {
+	prefix, err := url.PathUnescape(escaped)
+	if err != nil {
+		return "", fmt.Errorf("invalid prefix, use %%XX for bytes")
+	}
+	return prefix, nil
+}
+
+// validateEditedTransport checks the transport of the edit dialog in the
+// normalized form it is saved in. A #name in it is ignored.
+func validateEditedTransport(transportConfig string) error {
+	transportConfig, _ = splitTransportName(strings.TrimSpace(transportConfig))
+	if transportConfig == "" {
+		return fmt.Errorf("empty transport")
+	}
+	return validateTransport(normalizeTransport(transportConfig))
+}
+
+// Edit sets the name and transport of the config. A transport that is not the
+// same server, once normalized, resets the health, reports and history of the config
+// and detaches it from its dynamic access key and subscription, which would
+// otherwise overwrite or remove the edit on their next refresh.
+func (c *Config) Edit(name string, transportConfig string) error {
+	if err := validateEditedTransport(transportConfig); err != nil {
+		return err
+	}
+	transportConfig, _ = splitTransportName(strings.TrimSpace(transportConfig))
+	transportConfig = normalizeTransport(transportConfig)
+	c.Name = strings.TrimSpace(name)
+	if transportConfig == normalizeTransport(c.Transport) {
+		return nil
+	}
+	c.Transport = transportConfig
+	c.Health = 0
+	c.TestReports = []*connectivityReport{}
+	c.History = nil
+	c.KeyURL = ""
+	c.Subscription = ""
+	return nil
+}
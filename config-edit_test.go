@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitShadowsocksHop(t *testing.T) {
+	chain, server, ok := splitShadowsocksHop("split:3|" + testSSKey + "/?prefix=%16%03#Name")
+	require.True(t, ok)
+	assert.Equal(t, "split:3|", chain)
+	assert.Equal(t, ServerInfo{Server: "example.com", ServerPort: 443, Method: "chacha20-ietf-poly1305", Password: "secret", Prefix: "\x16\x03"}, *server)
+
+	server.Password = "changed"
+	transportConfig, err := joinShadowsocksHop(chain, server)
+	require.NoError(t, err)
+	assert.NoError(t, validateTransport(transportConfig))
+	_, changed, ok := splitShadowsocksHop(transportConfig)
+	require.True(t, ok)
+	assert.Equal(t, server, changed)
+
+	for _, transportConfig := range []string{"socks5://example.com:1080", testSSKey + "|split:3", "ss://notbase64@example.com:443", ""} {
+		_, _, ok := splitShadowsocksHop(transportConfig)
+		assert.False(t, ok, transportConfig)
+	}
+}
+
+func TestShadowsocksEditorFields(t *testing.T) {
+	for _, port := range []string{"", "0", "65536", "http"} {
+		_, err := parseServerPort(port)
+		assert.Error(t, err, port)
+	}
+	port, err := parseServerPort(" 8388 ")
+	require.NoError(t, err)
+	assert.Equal(t, 8388, port)
+
+	assert.Equal(t, "%16%03%01POST%20", escapePrefix("\x16\x03\x01POST "))
+	prefix, err := unescapePrefix("%16%03%01POST ")
+	require.NoError(t, err)
+	assert.Equal(t, "\x16\x03\x01POST ", prefix)
+	_, err = unescapePrefix("%zz")
+	assert.Error(t, err)
+}
+
+func TestConfigEdit(t *testing.T) {
+	tested := func() Config {
+		return Config{
+			Name:         "Old",
+			Transport:    testSSKey,
+			Health:       1,
+			TestReports:  []*connectivityReport{{}},
+			History:      []testRun{{Health: 1, LatencyMs: 42}},
+			KeyURL:       "ssconf://example.com/key",
+			Subscription: "https://example.com/sub",
+		}
+	}
+
+	// Renaming or rewriting the same server keeps the reports
+	c := tested()
+	require.NoError(t, c.Edit(" New ", "ss://chacha20-ietf-poly1305:secret@Example.com:443"))
+	assert.Equal(t, "New", c.Name)
+	assert.Equal(t, testSSKey, c.Transport)
+	assert.Equal(t, 1, c.Health)
+	assert.Len(t, c.TestReports, 1)
+	assert.Len(t, c.History, 1)
+	assert.NotEmpty(t, c.KeyURL)
+
+	// A different server resets them
+	c = tested()
+	require.NoError(t, c.Edit("Old", "split:3|"+testSSKey+"#Ignored"))
+	assert.Equal(t, "split:3|"+testSSKey, c.Transport)
+	assert.Equal(t, 0, c.Health)
+	assert.Empty(t, c.TestReports)
+	assert.Empty(t, c.History)
+	assert.Empty(t, c.KeyURL)
+	assert.Empty(t, c.Subscription)
+
+	// Invalid transports leave the config untouched
+	for _, transportConfig := range []string{"", "ss://notbase64@example.com:443", "split:3|", "hello"} {
+		c = tested()
+		assert.Error(t, c.Edit("New", transportConfig), transportConfig)
+		assert.Equal(t, tested(), c)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ConfigFormat is a file format for exported configs.
+type ConfigFormat string
+
+const (
+	ConfigFormatSIP008 ConfigFormat = "sip008"
+	ConfigFormatBase64 ConfigFormat = "base64"
+	ConfigFormatList   ConfigFormat = "list"
+)
+
+// FileName returns the default file name of an export in the format.
+func (f ConfigFormat) FileName() string {
+	switch f {
+	case ConfigFormatSIP008:
+		return "configs.json"
+	case ConfigFormatBase64:
+		return "subscription.txt"
+	default:
+		return "configs.txt"
+	}
+}
+
+// filterConfigsByHealth returns the configs whose health is one of healths.
+func filterConfigsByHealth(configs []Config, healths []int) []Config {
+	var filtered []Config
+	for _, c := range configs {
+		for _, health := range healths {
+			if c.Health == health {
+				filtered = append(filtered, c)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// ExportConfigs writes configs to w in the given format, with their names as
+// SIP002 remarks, so that the export imports back through parseDynamicConfig.
+// SIP008 JSON only holds single Shadowsocks servers; the names of other
+// configs are returned as skipped.
+func ExportConfigs(w io.Writer, configs []Config, format ConfigFormat) ([]string, error) {
+	var skipped []string
+	var data []byte
+	switch format {
+	case ConfigFormatSIP008:
+		document := SIP008Config{Version: 1, Servers: []ServerInfo{}}
+		for i := range configs {
+			server, err := parseShadowsocksURL(configs[i].Transport)
+			if err != nil {
+				skipped = append(skipped, configs[i].DisplayName())
+				continue
+			}
+			server.Remarks = strings.TrimSpace(configs[i].Name)
+			document.Servers = append(document.Servers, *server)
+		}
+		if len(document.Servers) == 0 {
+			return skipped, fmt.Errorf("no Shadowsocks configs to export as SIP008")
+		}
+		var err error
+		if data, err = json.MarshalIndent(document, "", "  "); err != nil {
+			return skipped, err
+		}
+		data = append(data, '\n')
+	case ConfigFormatBase64, ConfigFormatList:
+		if len(configs) == 0 {
+			return nil, fmt.Errorf("no configs to export")
+		}
+		var list strings.Builder
+		for i := range configs {
+			list.WriteString(withRemark(configs[i].Transport, configs[i].Name) + "\n")
+		}
+		data = []byte(list.String())
+		if format == ConfigFormatBase64 {
+			data = []byte(base64.StdEncoding.EncodeToString(data) + "\n")
+		}
+	default:
+		return nil, fmt.Errorf("unknown export format: %v", format)
+	}
+	_, err := w.Write(data)
+	return skipped, err
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -22,8 +24,68 @@ func makeConfigsPage(ctx *AppContext, navChannel chan NavEvent) fyne.CanvasObjec
 	// Create empty toolbar
 	headerToolbarRight := widget.NewToolbar()
 	header := makePageHeader("Test Result", headerToolbarLeft, headerToolbarRight)
+
+	configs := ctx.Settings.configsSnapshot()
+	shown := -1
+	for i := range configs {
+		if configs[i].ID == resultConfigID {
+			shown = i
+		}
+	}
+	if shown < 0 {
+		return container.NewVBox(header, widget.NewLabel("No config selected"))
+	}
+	cnf := &configs[shown]
+	if len(cnf.TestReports) == 0 {
+		return container.NewVBox(header, widget.NewLabel("Not tested yet"))
+	}
+	// Explain where the config ranks and why
+	rank := 0
+	for pos, i := range rankConfigs(configs) {
+		if i == shown {
+			rank = pos + 1
+		}
+	}
+	score := widget.NewLabel(fmt.Sprintf("Rank %d of %d\n%v", rank, len(configs), scoreConfig(cnf).Explain()))
+
+	// One accordion item per test report
+	accordion := widget.NewAccordion()
+	for _, r := range cnf.TestReports {
+		accordion.Append(widget.NewAccordionItem(
+			fmt.Sprintf("%v: %v", strings.ToUpper(r.Proto), r.Category.Title()),
+			makeReportDetails(r),
+		))
+	}
 	// Combine the header and the accordions in a vertical box layout
 	return container.NewVBox(
 		header,
+		score,
+		accordion,
 	)
 }
+
+// makeReportDetails shows the diagnosis and raw error of a single test report.
+func makeReportDetails(r *connectivityReport) fyne.CanvasObject {
+	details := widget.NewLabel(fmt.Sprintf("Resolver: %v\nDuration: %v ms", r.Resolver, r.DurationMs))
+	box := container.NewVBox(details)
+	if !r.IsSuccess() {
+		hint := widget.NewLabel(r.Category.Hint())
+		hint.Wrapping = fyne.TextWrapWord
+		raw := widget.NewLabel(fmt.Sprintf("%v %v %v", r.Error.Op, r.Error.PosixError, r.Error.Msg))
+		raw.Wrapping = fyne.TextWrapWord
+		raw.TextStyle = fyne.TextStyle{Monospace: true}
+		box.Add(hint)
+		box.Add(raw)
+	}
+	// Result of sending the report to each collector
+	for _, d := range r.Deliveries {
+		status := "collected"
+		if !d.Collected {
+			status = "failed: " + d.Error
+		}
+		delivery := widget.NewLabel(fmt.Sprintf("Report to %v: %v", d.Destination, status))
+		delivery.Wrapping = fyne.TextWrapWord
+		box.Add(delivery)
+	}
+	return box
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// duplicatePolicy is how an import handles configs that are already in the list.
+type duplicatePolicy string
+
+const (
+	// duplicateMerge keeps the existing config with its name, reports and health,
+	// and takes the transport and key URL of the imported one.
+	duplicateMerge duplicatePolicy = "merge"
+	// duplicateSkip leaves the existing config as it is.
+	duplicateSkip duplicatePolicy = "skip"
+	// duplicateReplace puts the imported config in place of the existing one.
+	duplicateReplace duplicatePolicy = "replace"
+)
+
+// dedupeResult counts how an import handled new and duplicate configs.
+type dedupeResult struct {
+	Added    int
+	Merged   []string
+	Skipped  []string
+	Replaced []string
+}
+
+// Summary describes the handled duplicates for the user.
+func (r dedupeResult) Summary() string {
+	lines := []string{fmt.Sprintf("Added: %d configs", r.Added)}
+	for _, handled := range []struct {
+		label string
+		names []string
+	}{{"Merged", r.Merged}, {"Skipped", r.Skipped}, {"Replaced", r.Replaced}} {
+		if len(handled.names) > 0 {
+			lines = append(lines, fmt.Sprintf("%v %d duplicates: %v", handled.label, len(handled.names), strings.Join(handled.names, ", ")))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isDuplicateConfig reports whether two configs are the same server: they
+// share a dynamic access key, or their transports normalize to the same form.
+func isDuplicateConfig(a, b *Config) bool {
+	if a.KeyURL != "" && a.KeyURL == b.KeyURL {
+		return true
+	}
+	return normalizeTransport(a.Transport) == normalizeTransport(b.Transport)
+}
+
+// countDuplicates returns how many imported configs are already in configs.
+func countDuplicates(configs []Config, imported []Config) int {
+	count := 0
+	for i := range imported {
+		for j := range configs {
+			if isDuplicateConfig(&configs[j], &imported[i]) {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+// mergeImportedConfigs adds imported configs to configs with normalized
+// transports. Duplicates of existing configs are handled by policy, and
+// duplicates within the import are always skipped.
+func mergeImportedConfigs(configs []Config, imported []Config, policy duplicatePolicy) ([]Config, dedupeResult) {
+	var result dedupeResult
+	existing := len(configs)
+	for _, c := range imported {
+		c.Transport = normalizeTransport(c.Transport)
+		if c.TestReports == nil {
+			c.TestReports = []*connectivityReport{}
+		}
+		found := -1
+		for j := range configs {
+			if isDuplicateConfig(&configs[j], &c) {
+				found = j
+				break
+			}
+		}
+		switch {
+		case found < 0:
+			c.ID = ""
+			configs = append(configs, c)
+			result.Added++
+		case found >= existing || policy == duplicateSkip:
+			result.Skipped = append(result.Skipped, c.DisplayName())
+		case policy == duplicateReplace:
+			result.Replaced = append(result.Replaced, configs[found].DisplayName())
+			// The replacement keeps the ID, so that it stays selected
+			c.ID = configs[found].ID
+			configs[found] = c
+		default:
+			merged := &configs[found]
+			result.Merged = append(result.Merged, merged.DisplayName())
+			if merged.Name == "" {
+				merged.Name = c.Name
+			}
+			if c.KeyURL != "" {
+				merged.KeyURL = c.KeyURL
+			}
+			// A rotated dynamic access key is a different server, as in refreshDynamicKey
+			if normalizeTransport(merged.Transport) != c.Transport {
+				merged.Health = 0
+				merged.TestReports = []*connectivityReport{}
+			}
+			merged.Transport = c.Transport
+		}
+	}
+	ensureConfigIDs(configs)
+	return configs, result
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeImportedConfigs(t *testing.T) {
+	repasted := "ss://chacha20-ietf-poly1305:secret@EXAMPLE.com:443"
+	other := "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpzZWNyZXQ@other.example.com:443"
+	existing := func() []Config {
+		return []Config{
+			{ID: "renamed", Name: "Renamed", Transport: testSSKey, Health: 1, TestReports: []*connectivityReport{{}}},
+			{ID: "rotated", Transport: "ss://old@rotated.example.com:443", KeyURL: "ssconf://example.com/key", Health: 2, TestReports: []*connectivityReport{{}}},
+		}
+	}
+	imported := []Config{
+		{Name: "Pasted", Transport: repasted},
+		{Name: "Dynamic", Transport: other, KeyURL: "ssconf://example.com/key"},
+		{Name: "New", Transport: "split:3|" + testSSKey},
+		{Name: "New again", Transport: "split:3 | " + repasted},
+	}
+
+	t.Run("merge", func(t *testing.T) {
+		configs, result := mergeImportedConfigs(existing(), imported, duplicateMerge)
+		require.Len(t, configs, 3)
+		assert.Equal(t, 1, result.Added)
+		assert.Equal(t, []string{"Renamed", "rotated.example.com:443"}, result.Merged)
+		assert.Equal(t, []string{"New again"}, result.Skipped)
+		assert.Equal(t, "Renamed", configs[0].Name)
+		assert.Equal(t, 1, configs[0].Health)
+		assert.Len(t, configs[0].TestReports, 1)
+		// The rotated key takes the imported server and name, and its stale reports are reset
+		assert.Equal(t, "Dynamic", configs[1].Name)
+		assert.Equal(t, other, configs[1].Transport)
+		assert.Equal(t, 0, configs[1].Health)
+		assert.Empty(t, configs[1].TestReports)
+		assert.Equal(t, "split:3|"+testSSKey, configs[2].Transport)
+		assert.NotNil(t, configs[2].TestReports)
+		assert.NotEmpty(t, configs[2].ID)
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		configs, result := mergeImportedConfigs(existing(), imported, duplicateSkip)
+		require.Len(t, configs, 3)
+		assert.Equal(t, existing()[:2], configs[:2])
+		assert.Equal(t, []string{"Pasted", "Dynamic", "New again"}, result.Skipped)
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		configs, result := mergeImportedConfigs(existing(), imported, duplicateReplace)
+		require.Len(t, configs, 3)
+		assert.Equal(t, []string{"Renamed", "rotated.example.com:443"}, result.Replaced)
+		// The replacement keeps the ID, so that a selected config stays selected
+		assert.Equal(t, Config{ID: "renamed", Name: "Pasted", Transport: testSSKey, TestReports: []*connectivityReport{}}, configs[0])
+		assert.Equal(t, "Dynamic", configs[1].Name)
+		assert.Contains(t, result.Summary(), "Replaced 2 duplicates: Renamed, rotated.example.com:443")
+	})
+}
+
+func TestMergeImportedConfigsKeepsRenames(t *testing.T) {
+	subscriptionURL := "https://example.com/sub"
+	configs := []Config{
+		{ID: "subscribed", Name: "My Tokyo", Transport: testSSKey, Subscription: subscriptionURL},
+		{ID: "dynamic", Name: "My key", Transport: "ss://old@rotated.example.com:443", KeyURL: "ssconf://example.com/key"},
+	}
+	// The subscription and the dynamic key are imported again with their own names
+	imported := append(subscriptionConfigs(subscriptionURL, []string{testSSKey + "#Tokyo 1"}),
+		Config{Name: "Dynamic", Transport: "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpzZWNyZXQ@other.example.com:443", KeyURL: "ssconf://example.com/key"})
+
+	configs, result := mergeImportedConfigs(configs, imported, duplicateMerge)
+	require.Len(t, configs, 2)
+	assert.Equal(t, []string{"My Tokyo", "My key"}, result.Merged)
+	assert.Equal(t, "My Tokyo", configs[0].Name)
+	assert.Equal(t, "My key", configs[1].Name)
+	assert.Equal(t, []string{"subscribed", "dynamic"}, []string{configs[0].ID, configs[1].ID})
+}
+
+func TestCountDuplicates(t *testing.T) {
+	configs := []Config{{Transport: testSSKey}}
+	assert.Equal(t, 0, countDuplicates(nil, []Config{{Transport: testSSKey}}))
+	assert.Equal(t, 1, countDuplicates(configs, []Config{
+		{Transport: "ss://chacha20-ietf-poly1305:secret@Example.com:443"},
+		{Transport: "split:3|" + testSSKey},
+	}))
+}
+
+func TestDedupeResultSummary(t *testing.T) {
+	summary := dedupeResult{Added: 2, Merged: []string{"A", "B"}}.Summary()
+	assert.Equal(t, "Added: 2 configs\nMerged 2 duplicates: A, B", summary)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strings"
+)
+
+// failureCategory is a human-readable classification of a failed connectivity test.
+type failureCategory string
+
+const (
+	categoryNone        failureCategory = ""
+	categoryUnreachable failureCategory = "server_unreachable"
+	categoryReset       failureCategory = "tcp_reset"
+	categoryAuth        failureCategory = "wrong_credentials"
+	categoryDNSBlocked  failureCategory = "dns_blocked"
+	categoryUDPBlocked  failureCategory = "udp_blocked"
+	categoryTimeout     failureCategory = "timeout"
+	categoryUnknown     failureCategory = "unknown"
+)
+
+// Title returns a short label for the category, suitable for list rows.
+func (c failureCategory) Title() string {
+	switch c {
+	case categoryNone:
+		return "OK"
+	case categoryUnreachable:
+		return "Server unreachable"
+	case categoryReset:
+		return "Connection reset"
+	case categoryAuth:
+		return "Wrong password or cipher"
+	case categoryDNSBlocked:
+		return "DNS blocked"
+	case categoryUDPBlocked:
+		return "UDP blocked"
+	case categoryTimeout:
+		return "Timeout"
+	default:
+		return "Unknown error"
+	}
+}
+
+// Hint returns a short remediation hint for the category.
+func (c failureCategory) Hint() string {
+	switch c {
+	case categoryNone:
+		return ""
+	case categoryUnreachable:
+		return "The server refused the connection or has no route. Check that the host and port are correct and the server is running."
+	case categoryReset:
+		return "The connection was reset in flight, which often means DPI. Try a prefix or a split:/tlsfrag: wrapper."
+	case categoryAuth:
+		return "The server closed the connection after the handshake. Check the password and cipher of the access key."
+	case categoryDNSBlocked:
+		return "The server hostname could not be resolved. Try a config that uses an IP address or a different resolver."
+	case categoryUDPBlocked:
+		return "UDP traffic got no response. UDP may be blocked on this network; TCP may still work."
+	case categoryTimeout:
+		return "The server did not answer in time. It may be overloaded, far away or silently blocked."
+	default:
+		return "The test failed for an unrecognized reason. See the error message for details."
+	}
+}
+
+// classifyError maps a connectivity test error into a failureCategory.
+// A nil error is classified as categoryNone.
+func classifyError(proto string, e *errorJSON) failureCategory {
+	if e == nil {
+		return categoryNone
+	}
+	msg := strings.ToLower(e.Msg)
+	switch {
+	case strings.Contains(msg, "no such host"), strings.Contains(msg, "server misbehaving"):
+		return categoryDNSBlocked
+	case strings.Contains(msg, "cipher"), strings.Contains(msg, "authentication failed"), strings.Contains(msg, "password"):
+		return categoryAuth
+	}
+	switch e.PosixError {
+	case "ECONNREFUSED", "EHOSTUNREACH", "ENETUNREACH", "EHOSTDOWN", "ENETDOWN":
+		return categoryUnreachable
+	case "ECONNRESET", "EPIPE", "ECONNABORTED":
+		return categoryReset
+	case "ETIMEDOUT":
+		if proto == "udp" && e.Op != "connect" {
+			return categoryUDPBlocked
+		}
+		return categoryTimeout
+	}
+	// An early close after a successful connect is how Shadowsocks servers
+	// reject clients with the wrong key.
+	if e.Op == "receive" && proto == "tcp" && strings.Contains(msg, "eof") {
+		return categoryAuth
+	}
+	return categoryUnknown
+}
+
+// Diagnosis returns the failure category of the config's first failed test report.
+func (c *Config) Diagnosis() failureCategory {
+	for _, r := range c.TestReports {
+		if r != nil && r.Category != categoryNone {
+			return r.Category
+		}
+	}
+	return categoryNone
+}
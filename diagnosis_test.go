@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name  string
+		proto string
+		err   *errorJSON
+		want  failureCategory
+	}{
+		{"success", "tcp", nil, categoryNone},
+		{"refused", "tcp", &errorJSON{Op: "connect", PosixError: "ECONNREFUSED", Msg: "connection refused"}, categoryUnreachable},
+		{"reset", "tcp", &errorJSON{Op: "receive", PosixError: "ECONNRESET", Msg: "connection reset by peer"}, categoryReset},
+		{"early close", "tcp", &errorJSON{Op: "receive", Msg: "unexpected EOF"}, categoryAuth},
+		{"bad cipher", "tcp", &errorJSON{Msg: "cipher not supported: foo"}, categoryAuth},
+		{"dns", "tcp", &errorJSON{Op: "connect", Msg: "lookup example.invalid: no such host"}, categoryDNSBlocked},
+		{"udp silence", "udp", &errorJSON{Op: "receive", PosixError: "ETIMEDOUT", Msg: "i/o timeout"}, categoryUDPBlocked},
+		{"tcp timeout", "tcp", &errorJSON{Op: "connect", PosixError: "ETIMEDOUT", Msg: "i/o timeout"}, categoryTimeout},
+		{"other", "tcp", &errorJSON{Op: "send", Msg: "something odd"}, categoryUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, classifyError(tt.proto, tt.err))
+		})
+	}
+}
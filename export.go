@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-sdk/x/config"
+)
+
+// ReportFormat is a file format for exported test reports.
+type ReportFormat string
+
+const (
+	FormatJSONLines ReportFormat = "jsonl"
+	FormatCSV       ReportFormat = "csv"
+)
+
+// exportRecord is one exported test report or history entry.
+type exportRecord struct {
+	Config    string              `json:"config"`
+	Transport string              `json:"transport"`
+	Kind      string              `json:"kind"`
+	Report    *connectivityReport `json:"report,omitempty"`
+	History   *testRun            `json:"history,omitempty"`
+}
+
+var csvHeader = []string{
+	"config", "transport", "kind", "time", "proto", "resolver", "duration_ms",
+	"health", "latency_ms", "error_op", "posix_error", "error_msg", "category", "run_id",
+	"app_version", "os", "arch", "test_type", "test_domain", "network_type", "schema_version",
+	"config_hash", "throughput_kbps",
+}
+
+// ExportReports writes the test reports and history of the configs to w in the given format.
+// Transports are sanitized so that no secrets are exported.
+func ExportReports(w io.Writer, configs []Config, format ReportFormat) error {
+	records := exportRecords(configs)
+	switch format {
+	case FormatJSONLines:
+		encoder := json.NewEncoder(w)
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return err
+		}
+		for _, record := range records {
+			if err := cw.Write(record.csvRow()); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unknown export format: %v", format)
+	}
+}
+
+// exportRecords flattens the configs' reports and history into sanitized records.
+func exportRecords(configs []Config) []exportRecord {
+	var records []exportRecord
+	for i := range configs {
+		c := &configs[i]
+		transport, err := config.SanitizeConfig(c.Transport)
+		if err != nil {
+			transport = "invalid config"
+		}
+		for _, r := range c.TestReports {
+			sanitized := *r
+			sanitized.Transport = transport
+			records = append(records, exportRecord{Config: c.DisplayName(), Transport: transport, Kind: "report", Report: &sanitized})
+		}
+		for j := range c.History {
+			records = append(records, exportRecord{Config: c.DisplayName(), Transport: transport, Kind: "history", History: &c.History[j]})
+		}
+	}
+	return records
+}
+
+// csvRow flattens the record into the csvHeader columns.
+func (e exportRecord) csvRow() []string {
+	row := make([]string, len(csvHeader))
+	row[0], row[1], row[2] = e.Config, e.Transport, e.Kind
+	if r := e.Report; r != nil {
+		row[3] = r.Time.Format(time.RFC3339)
+		row[4] = r.Proto
+		row[5] = r.Resolver
+		row[6] = strconv.FormatInt(r.DurationMs, 10)
+		if r.Error != nil {
+			row[9], row[10], row[11] = r.Error.Op, r.Error.PosixError, r.Error.Msg
+		}
+		row[12] = string(r.Category)
+		row[13] = r.RunID
+		row[14], row[15], row[16] = r.AppVersion, r.OS, r.Arch
+		row[17], row[18], row[19] = r.TestType, r.TestDomain, r.NetworkType
+		row[20] = strconv.Itoa(r.SchemaVersion)
+		row[21] = r.ConfigHash
+	}
+	if h := e.History; h != nil {
+		row[3] = h.Time.Format(time.RFC3339)
+		row[7] = strconv.Itoa(h.Health)
+		row[8] = strconv.FormatInt(h.LatencyMs, 10)
+		row[22] = strconv.FormatInt(h.ThroughputKbps, 10)
+	}
+	return row
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newExportConfigs() []Config {
+	when := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	return []Config{{
+		Transport: "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpzZWNyZXQ@example.com:443",
+		TestReports: []*connectivityReport{
+			{SchemaVersion: reportSchemaVersion, Proto: "tcp", Transport: "ss://REDACTED@example.com:443", Time: when, DurationMs: 42,
+				TestType: "dns", TestDomain: "example.org", AppVersion: "1.2.3", OS: "linux", Arch: "amd64", NetworkType: "wifi", ConfigHash: "abc123"},
+			{Proto: "udp", Transport: "ss://REDACTED@example.com:443", Time: when, Error: &errorJSON{Op: "receive", PosixError: "ETIMEDOUT"}, Category: categoryUDPBlocked},
+		},
+		History: []testRun{{Time: when, Health: 2, LatencyMs: 42, ThroughputKbps: 850}},
+	}}
+}
+
+func TestExportReportsJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, ExportReports(&buf, newExportConfigs(), FormatJSONLines))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	var record exportRecord
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+	assert.Equal(t, "report", record.Kind)
+	assert.Equal(t, "udp", record.Report.Proto)
+	assert.Equal(t, categoryUDPBlocked, record.Report.Category)
+	assert.NotContains(t, buf.String(), "Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpzZWNyZXQ")
+}
+
+func TestExportReportsCSV(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, ExportReports(&buf, newExportConfigs(), FormatCSV))
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 4)
+	assert.Equal(t, csvHeader, rows[0])
+	assert.Equal(t, "history", rows[3][2])
+	assert.Equal(t, "2", rows[3][7])
+	tcp, history := map[string]string{}, map[string]string{}
+	for i, column := range csvHeader {
+		tcp[column] = rows[1][i]
+		history[column] = rows[3][i]
+	}
+	assert.Equal(t, "abc123", tcp["config_hash"])
+	assert.Equal(t, "850", history["throughput_kbps"])
+	assert.Equal(t, "1.2.3", tcp["app_version"])
+	assert.Equal(t, "linux", tcp["os"])
+	assert.Equal(t, "amd64", tcp["arch"])
+	assert.Equal(t, "dns", tcp["test_type"])
+	assert.Equal(t, "example.org", tcp["test_domain"])
+	assert.Equal(t, "wifi", tcp["network_type"])
+	assert.Equal(t, strconv.Itoa(reportSchemaVersion), tcp["schema_version"])
+	for _, row := range rows[1:] {
+		assert.NotContains(t, row[1], "secret")
+		assert.Contains(t, row[1], "REDACTED")
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+	"github.com/Jigsaw-Code/outline-sdk/x/config"
+)
+
+// fetchTimeout bounds fetching a config list or a dynamic access key,
+// including reading the body.
+const fetchTimeout = 15 * time.Second
+
+// fetchClient is the HTTP client that fetches config lists and dynamic access
+// keys directly. Routed fetches reuse its TLS settings.
+var fetchClient = &http.Client{Timeout: fetchTimeout}
+
+// fetchRoute is how config lists and dynamic access keys are fetched.
+type fetchRoute string
+
+const (
+	fetchDirect    fetchRoute = ""
+	fetchViaTunnel fetchRoute = "tunnel"
+	fetchViaConfig fetchRoute = "config"
+)
+
+// fetcher fetches config lists and dynamic access keys with a size limit and
+// the app's User-Agent. Responses outside 2xx are errors.
+type fetcher struct {
+	client *http.Client
+}
+
+// fetchResponse is a fetched response with its body read.
+type fetchResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// directFetcher fetches directly.
+func directFetcher() *fetcher {
+	return &fetcher{client: fetchClient}
+}
+
+// fetchUserAgent is the User-Agent of fetch requests.
+func fetchUserAgent() string {
+	if appVersion == "" {
+		return "BlazerProxy"
+	}
+	return "BlazerProxy/" + appVersion
+}
+
+// get fetches rawURL with the extra headers of the request and reads at most
+// maxSize bytes of the body. The headers, which may hold a subscription token
+// or replace the User-Agent, are only sent to the origin of rawURL and are
+// dropped on redirects to other origins. A 304 Not Modified answer to a
+// conditional request is returned without error. Errors leave out the URL, as
+// its path or query may hold a subscription token.
+func (f *fetcher) get(rawURL string, header http.Header, maxSize int64) (*fetchResponse, error) {
+	request, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL")
+	}
+	request.Header.Set("User-Agent", fetchUserAgent())
+	for key, values := range header {
+		request.Header[http.CanonicalHeaderKey(key)] = values
+	}
+	client := *f.client
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if req.URL.Scheme != "https" {
+			return fmt.Errorf("redirect to %v:// refused", req.URL.Scheme)
+		}
+		if len(via) >= 5 {
+			return fmt.Errorf("too many redirects")
+		}
+		// The client copies the headers of the first request to redirects
+		if !sameOrigin(req.URL, request.URL) {
+			for key := range header {
+				req.Header.Del(key)
+			}
+			req.Header.Set("User-Agent", fetchUserAgent())
+		}
+		return nil
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("%v: %w", request.URL.Host, err)
+	}
+	defer response.Body.Close()
+	conditional := request.Header.Get("If-None-Match") != "" || request.Header.Get("If-Modified-Since") != ""
+	if response.StatusCode == http.StatusNotModified && conditional {
+		return &fetchResponse{StatusCode: response.StatusCode, Header: response.Header}, nil
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, fmt.Errorf("%v responded %v", request.URL.Host, response.Status)
+	}
+	if response.ContentLength > maxSize {
+		return nil, fmt.Errorf("response is larger than %d KB", maxSize>>10)
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if int64(len(body)) > maxSize {
+		return nil, fmt.Errorf("response is larger than %d KB", maxSize>>10)
+	}
+	return &fetchResponse{StatusCode: response.StatusCode, Header: response.Header, Body: body}, nil
+}
+
+// sameOrigin reports whether two URLs have the same scheme, host and port.
+func sameOrigin(a, b *url.URL) bool {
+	port := func(u *url.URL) string {
+		if p := u.Port(); p != "" {
+			return p
+		}
+		if u.Scheme == "http" {
+			return "80"
+		}
+		return "443"
+	}
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Hostname(), b.Hostname()) && port(a) == port(b)
+}
+
+// configFetcher returns the fetcher for the fetch route of the settings.
+func (s *AppSettings) configFetcher() (*fetcher, error) {
+	f := &fetcher{client: fetchClient}
+	switch s.FetchRoute {
+	case fetchDirect:
+	case fetchViaTunnel:
+		address := runningProxyAddress()
+		if address == "" {
+			return nil, fmt.Errorf("cannot fetch through the tunnel, it is not running")
+		}
+		f.client = routedClient(func(t *http.Transport) {
+			t.Proxy = http.ProxyURL(&url.URL{Scheme: "http", Host: address})
+		})
+	case fetchViaConfig:
+		c, ok := s.configByID(s.FetchConfigID)
+		if !ok {
+			return nil, fmt.Errorf("the config to fetch through was removed")
+		}
+		dialer, err := config.NewDefaultConfigParser().WrapStreamDialer(&transport.TCPDialer{}, c.Transport)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config to fetch through")
+		}
+		f.client = routedClient(func(t *http.Transport) {
+			t.Proxy = nil
+			t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return dialer.DialStream(ctx, addr)
+			}
+		})
+	default:
+		return nil, fmt.Errorf("unknown fetch route: %v", s.FetchRoute)
+	}
+	return f, nil
+}
+
+// routedClient returns a copy of fetchClient with its transport changed by route.
+func routedClient(route func(*http.Transport)) *http.Client {
+	base, ok := fetchClient.Transport.(*http.Transport)
+	if !ok || base == nil {
+		base = http.DefaultTransport.(*http.Transport)
+	}
+	t := base.Clone()
+	route(t)
+	return &http.Client{Timeout: fetchClient.Timeout, Transport: t}
+}
+
+// parseHeaderLines parses extra request headers from "Name: value" lines.
+func parseHeaderLines(lines []string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, line := range lines {
+		name, value, found := strings.Cut(line, ":")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !found || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid header line, expected \"Name: value\"")
+		}
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+	return headers, nil
+}
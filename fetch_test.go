@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFetchServer serves handler over TLS and points fetchClient at it.
+func newFetchServer(t *testing.T, handler http.HandlerFunc) string {
+	server := httptest.NewTLSServer(handler)
+	original := fetchClient
+	fetchClient = server.Client()
+	t.Cleanup(func() {
+		fetchClient = original
+		server.CloseClientConnections()
+		server.Close()
+	})
+	return server.URL
+}
+
+func TestFetcherRejectsMisbehavingServers(t *testing.T) {
+	tests := map[string]struct {
+		handler http.HandlerFunc
+		err     string
+	}{
+		"server error": {func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "oops", http.StatusInternalServerError)
+		}, "500 Internal Server Error"},
+		"redirect to http": {func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "http://example.com/list", http.StatusFound)
+		}, "redirect to http:// refused"},
+		"redirect loop": {func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, r.URL.String(), http.StatusFound)
+		}, "too many redirects"},
+		"declared too large": {func(w http.ResponseWriter, r *http.Request) {
+			w.Write(bytes.Repeat([]byte("a"), 2048))
+		}, "larger than 1 KB"},
+		"streamed too large": {func(w http.ResponseWriter, r *http.Request) {
+			for i := 0; i < 4; i++ {
+				w.Write(bytes.Repeat([]byte("a"), 512))
+				w.(http.Flusher).Flush()
+			}
+		}, "larger than 1 KB"},
+		"unconditional not modified": {func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotModified)
+		}, "304 Not Modified"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			serverURL := newFetchServer(t, tt.handler)
+			_, err := directFetcher().get(serverURL+"/list?token=SECRET", nil, 1024)
+			assert.ErrorContains(t, err, tt.err)
+			assert.NotContains(t, err.Error(), "SECRET")
+		})
+	}
+}
+
+func TestFetcherTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	serverURL := newFetchServer(t, func(w http.ResponseWriter, r *http.Request) {
+		// Send part of the body, then stall
+		w.Write([]byte("ss://"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	fetchClient.Timeout = 200 * time.Millisecond
+
+	start := time.Now()
+	_, err := directFetcher().get(serverURL+"/list", nil, 1024)
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestFetcherHeaders(t *testing.T) {
+	var received http.Header
+	serverURL := newFetchServer(t, func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte(testSSKey))
+	})
+
+	f := directFetcher()
+	response, err := f.get(serverURL, http.Header{"authorization": {"Bearer token"}}, 1024)
+	require.NoError(t, err)
+	assert.Equal(t, testSSKey, string(response.Body))
+	assert.True(t, strings.HasPrefix(received.Get("User-Agent"), "BlazerProxy"))
+	assert.Equal(t, "Bearer token", received.Get("Authorization"))
+
+	// Conditional requests may be answered with 304 Not Modified
+	response, err = f.get(serverURL, http.Header{"If-None-Match": {`"v1"`}}, 1024)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotModified, response.StatusCode)
+
+	// Extra headers may replace the User-Agent
+	_, err = f.get(serverURL, http.Header{"User-Agent": {"ClashForAndroid/2.5"}}, 1024)
+	require.NoError(t, err)
+	assert.Equal(t, "ClashForAndroid/2.5", received.Get("User-Agent"))
+}
+
+func TestFetcherDropsHeadersOnCrossOriginRedirect(t *testing.T) {
+	var received http.Header
+	other := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.Write([]byte(testSSKey))
+	}))
+	defer other.Close()
+	serverURL := newFetchServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved" {
+			http.Redirect(w, r, "/list", http.StatusFound)
+			return
+		}
+		if r.URL.Path == "/list" {
+			received = r.Header.Clone()
+			w.Write([]byte(testSSKey))
+			return
+		}
+		http.Redirect(w, r, other.URL+"/list", http.StatusFound)
+	})
+	header := http.Header{"Authorization": {"Bearer token"}, "X-Token": {"secret"}, "User-Agent": {"ClashForAndroid/2.5"}}
+
+	// Redirects within the origin keep the headers
+	_, err := directFetcher().get(serverURL+"/moved", header, 1024)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", received.Get("X-Token"))
+
+	_, err = directFetcher().get(serverURL+"/elsewhere", header, 1024)
+	require.NoError(t, err)
+	assert.Empty(t, received.Get("Authorization"))
+	assert.Empty(t, received.Get("X-Token"))
+	assert.True(t, strings.HasPrefix(received.Get("User-Agent"), "BlazerProxy"))
+}
+
+func TestSubscriptionHeadersOnlyGoToTheirList(t *testing.T) {
+	var received []http.Header
+	serverURL := newFetchServer(t, func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Clone())
+		w.Write([]byte(testSSKey))
+	})
+
+	setting := &AppSettings{}
+	_, err := setting.AddSubscription(serverURL+"/a", map[string]string{"X-Token": "a"})
+	require.NoError(t, err)
+	_, err = setting.AddSubscription(serverURL+"/b", nil)
+	require.NoError(t, err)
+	require.Len(t, received, 2)
+	assert.Equal(t, "a", received[0].Get("X-Token"))
+	assert.Empty(t, received[1].Get("X-Token"))
+	assert.Equal(t, map[string]string{"X-Token": "a"}, setting.Subscriptions[0].Headers)
+}
+
+func TestGetDynamicConfigErrors(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	serverURL := newFetchServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte("<html><body>Please wait</body></html>"))
+	})
+	_, err := getDynamicConfig(serverURL, directFetcher())
+	assert.ErrorContains(t, err, "503")
+
+	status = http.StatusOK
+	_, err = getDynamicConfig(serverURL, directFetcher())
+	assert.ErrorContains(t, err, "HTML")
+}
+
+func TestFetcherRoutes(t *testing.T) {
+	serverURL := newFetchServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testSSKey))
+	})
+
+	setting := &AppSettings{FetchRoute: fetchViaTunnel}
+	_, err := setting.configFetcher()
+	assert.ErrorContains(t, err, "not running")
+
+	// The tunnel is an HTTP proxy that tunnels with CONNECT
+	var connected []string
+	tunnel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		connected = append(connected, r.Host)
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		go func() {
+			io.Copy(upstream, conn)
+			upstream.Close()
+		}()
+		io.Copy(conn, upstream)
+		conn.Close()
+	}))
+	defer tunnel.Close()
+	setRunningProxy(&runningProxy{Address: strings.TrimPrefix(tunnel.URL, "http://")})
+	defer setRunningProxy(nil)
+	f, err := setting.configFetcher()
+	require.NoError(t, err)
+	response, err := f.get(serverURL, nil, 1024)
+	require.NoError(t, err)
+	assert.Equal(t, testSSKey, string(response.Body))
+	assert.Equal(t, []string{strings.TrimPrefix(serverURL, "https://")}, connected)
+
+	// Fetch through a config, which must still be in the list
+	setting = &AppSettings{FetchRoute: fetchViaConfig, FetchConfigID: "fetch"}
+	_, err = setting.configFetcher()
+	assert.ErrorContains(t, err, "removed")
+	setting.Configs = []Config{{ID: "fetch", Transport: "split:2", Health: 1}}
+	f, err = setting.configFetcher()
+	require.NoError(t, err)
+	response, err = f.get(serverURL, nil, 1024)
+	require.NoError(t, err)
+	assert.Equal(t, testSSKey, string(response.Body))
+}
+
+func TestParseHeaderLines(t *testing.T) {
+	headers, err := parseHeaderLines([]string{"authorization: Bearer a:b", "X-Client:  fyne "})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"Authorization": "Bearer a:b", "X-Client": "fyne"}, headers)
+
+	for _, line := range []string{"no colon", ": value", "Bad Name: value"} {
+		_, err := parseHeaderLines([]string{line})
+		assert.Error(t, err, line)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// maxAcceptedInSummary limits the accepted configs listed in a file import summary.
+const maxAcceptedInSummary = 20
+
+// isImageFile reports whether the file name has the extension of an image
+// that may hold a QR code.
+func isImageFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".png", ".jpg", ".jpeg":
+		return true
+	}
+	return false
+}
+
+// importConfigFile reads a local config file and detects its format like a
+// subscription's config list. The QR code of an image file is decoded first.
+func importConfigFile(name string, r io.Reader) ([]Config, *importResult, error) {
+	var data []byte
+	if isImageFile(name) {
+		image, err := readImage(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		text, err := decodeQRImage(image)
+		if err != nil {
+			return nil, nil, err
+		}
+		data = []byte(text)
+	} else {
+		var err error
+		if data, err = io.ReadAll(io.LimitReader(r, maxSubscriptionSize+1)); err != nil {
+			return nil, nil, err
+		}
+		if len(data) > maxSubscriptionSize {
+			return nil, nil, fmt.Errorf("file is larger than %d KB", maxSubscriptionSize>>10)
+		}
+	}
+	result, err := parseDynamicConfig(data)
+	if err != nil {
+		return nil, result, err
+	}
+	return subscriptionConfigs("", result.Accepted), result, nil
+}
+
+// fileImportSummary describes the configs imported from a file by name,
+// followed by the summary of the import. It never shows secrets.
+func fileImportSummary(name string, configs []Config, result *importResult) string {
+	lines := []string{"File: " + name}
+	if len(configs) > 0 {
+		var names []string
+		for i := range configs {
+			if i == maxAcceptedInSummary {
+				names = append(names, fmt.Sprintf("... and %d more", len(configs)-i))
+				break
+			}
+			names = append(names, configs[i].DisplayName())
+		}
+		lines = append(lines, "Imported: "+strings.Join(names, ", "))
+	}
+	if result != nil {
+		lines = append(lines, result.Summary())
+	}
+	return strings.Join(lines, "\n")
+}
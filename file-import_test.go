@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportConfigFile(t *testing.T) {
+	tests := []struct {
+		file     string
+		format   configFormat
+		accepted int
+	}{
+		{"subscriptions/plain_crlf.txt", formatPlainList, 3},
+		{"subscriptions/std.txt", formatBase64Std, 3},
+		{"clash/proxies.yaml", formatClashYAML, 3},
+		{"qr/access-key.png", formatPlainList, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", tt.file))
+			require.NoError(t, err)
+			defer f.Close()
+			configs, result, err := importConfigFile(filepath.Base(tt.file), f)
+			require.NoError(t, err)
+			assert.Equal(t, tt.format, result.Format)
+			require.Len(t, configs, tt.accepted)
+			for _, c := range configs {
+				assert.Empty(t, c.Subscription)
+				assert.NotContains(t, c.Transport, "#")
+			}
+		})
+	}
+}
+
+func TestImportConfigFileSIP008(t *testing.T) {
+	var export bytes.Buffer
+	_, err := ExportConfigs(&export, []Config{{Name: "Tokyo", Transport: testSSKey}}, ConfigFormatSIP008)
+	require.NoError(t, err)
+	configs, result, err := importConfigFile("configs.json", &export)
+	require.NoError(t, err)
+	assert.Equal(t, formatSIP008, result.Format)
+	require.Len(t, configs, 1)
+	assert.Equal(t, "Tokyo", configs[0].Name)
+}
+
+func TestImportConfigFileRejected(t *testing.T) {
+	_, result, err := importConfigFile("list.txt", strings.NewReader("hello\nss://notbase64@example.com:443\n"))
+	require.Error(t, err)
+	require.NotNil(t, result)
+	summary := fileImportSummary("list.txt", nil, result)
+	assert.Contains(t, summary, "File: list.txt")
+	assert.Contains(t, summary, "line 1: not a config URL")
+	assert.Contains(t, summary, "line 2: invalid ss config")
+	assert.NotContains(t, summary, "notbase64")
+}
+
+func TestImportConfigFileInvalid(t *testing.T) {
+	_, result, err := importConfigFile("page.html", strings.NewReader("<html></html>"))
+	assert.Error(t, err)
+	assert.Nil(t, result)
+
+	_, _, err = importConfigFile("big.txt", strings.NewReader(strings.Repeat("a", maxSubscriptionSize+1)))
+	assert.ErrorContains(t, err, "file is larger than")
+
+	_, _, err = importConfigFile("no-code.png", bytes.NewReader(readFixture(t, "qr", "no-code.png")))
+	assert.Error(t, err)
+}
+
+func TestFileImportSummary(t *testing.T) {
+	configs := []Config{{Name: "Tokyo", Transport: testSSKey}}
+	for i := 0; i < maxAcceptedInSummary+2; i++ {
+		configs = append(configs, Config{Transport: testSSKey})
+	}
+	summary := fileImportSummary("list.txt", configs, &importResult{Format: formatPlainList})
+	assert.Contains(t, summary, "Imported: Tokyo, ")
+	assert.Contains(t, summary, "... and 3 more")
+	assert.Contains(t, summary, "Format: plain list")
+	assert.NotContains(t, summary, "ss://")
+}
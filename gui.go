@@ -20,6 +20,9 @@ func makePageContent(ctx *AppContext, state *AppState, navChannel chan NavEvent)
 	case "configs":
 		fmt.Println("rendering the test result page")
 		return makeConfigsPage(ctx, navChannel)
+	case "compare":
+		fmt.Println("rendering the compare page")
+		return makeComparePage(ctx, navChannel)
 	// Add more cases for different pages
 	default:
 		return widget.NewLabel("Page not found")
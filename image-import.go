@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxImageSize limits the size of image files imported as QR codes.
+const maxImageSize = 20 << 20
+
+// maxImagePixels limits the size of decoded images, as small files can
+// decompress into huge images.
+const maxImagePixels = 40_000_000
+
+// decodeQRImage decodes the QR code of a PNG or JPEG image.
+func decodeQRImage(data []byte) (string, error) {
+	imageConfig, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("not a PNG or JPEG image")
+	}
+	if imageConfig.Width*imageConfig.Height > maxImagePixels {
+		return "", fmt.Errorf("image is too large: %dx%d", imageConfig.Width, imageConfig.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("invalid image: %w", err)
+	}
+	return decodeQRCode(img)
+}
+
+// readImage reads an image file of at most maxImageSize bytes.
+func readImage(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxImageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImageSize {
+		return nil, fmt.Errorf("image is larger than %d MB", maxImageSize>>20)
+	}
+	return data, nil
+}
+
+// clipboardImage returns the image that the clipboard text refers to. Fyne
+// only reads text from the clipboard, so images are pasted as a data:image
+// URL, or as the path or file:// URI that file managers copy for image files.
+// isImage is false if the text is not an image reference.
+func clipboardImage(text string) (data []byte, isImage bool, err error) {
+	text = strings.TrimSpace(text)
+	if strings.ContainsAny(text, "\r\n") {
+		return nil, false, nil
+	}
+	if strings.HasPrefix(strings.ToLower(text), "data:image/") {
+		header, payload, _ := strings.Cut(text, ",")
+		if !strings.HasSuffix(strings.ToLower(header), ";base64") {
+			return nil, true, fmt.Errorf("unsupported image data URL")
+		}
+		data, _, err := decodeBase64(payload)
+		if err != nil {
+			return nil, true, fmt.Errorf("invalid image data URL: %w", err)
+		}
+		return data, true, nil
+	}
+	path := text
+	if strings.HasPrefix(strings.ToLower(text), "file://") {
+		u, err := url.Parse(text)
+		if err != nil {
+			return nil, false, nil
+		}
+		path = u.Path
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".jpg", ".jpeg":
+	default:
+		return nil, false, nil
+	}
+	if !filepath.IsAbs(path) {
+		return nil, false, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, true, err
+	}
+	defer f.Close()
+	data, err = readImage(f)
+	return data, true, err
+}
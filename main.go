@@ -4,14 +4,15 @@ import (
 	"encoding/json"
 	"log"
 	"net/url"
+	"strings"
+	"sync"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
+	"github.com/Jigsaw-Code/outline-sdk/x/config"
 	"github.com/Jigsaw-Code/outline-sdk/x/sysproxy"
 )
 
-var selectedItemID int
-
 type AppState struct {
 	CurrentPage string
 }
@@ -31,13 +32,55 @@ type AppSettings struct {
 	SmartConfig    []byte   `json:"smartConfig"`
 	SmartConfigURL url.URL  `json:"smartConfigURL"`
 	BlockedDomains []string `json:"blockedDomains"`
+	AutoSelect     bool     `json:"autoSelect"`
+	// ThroughputURL is downloaded through configs that pass their tests to
+	// measure their throughput. Empty disables the measurement.
+	ThroughputURL string `json:"throughputURL"`
+	// Outbox holds reports that could not be collected yet.
+	Outbox          []*connectivityReport `json:"outbox,omitempty"`
+	ReportViaTunnel bool                  `json:"reportViaTunnel"`
+	ReportPrivacy   ReportPrivacy         `json:"reportPrivacy"`
+	// Collectors are the report destinations. If empty, ReporterURL is used.
+	Collectors []CollectorDestination `json:"collectors,omitempty"`
+	// Subscriptions are the remote config lists the configs are refreshed from.
+	Subscriptions []Subscription `json:"subscriptions,omitempty"`
+	// FetchRoute is how config lists and dynamic access keys are fetched.
+	// FetchConfigID is the ID of the config used with fetchViaConfig.
+	FetchRoute    fetchRoute `json:"fetchRoute,omitempty"`
+	FetchConfigID string     `json:"fetchConfigId,omitempty"`
 }
 
 type Config struct {
+	// ID identifies the config while its index changes, see ensureConfigIDs.
+	ID string `json:"id,omitempty"`
+	// Name is the user-visible name, taken from the SIP002 fragment or SIP008 remarks.
+	Name        string                `json:"name,omitempty"`
 	Transport   string                `json:"transport"`
 	ConfigFile  []byte                `json:"configFile"`
 	TestReports []*connectivityReport `json:"testReport"`
 	Health      int                   `json:"health"`
+	History     []testRun             `json:"history,omitempty"`
+	// KeyURL is the ssconf:// URL of a dynamic access key, used to re-fetch it.
+	KeyURL string `json:"keyURL,omitempty"`
+	// Subscription is the URL of the config list the config was imported from.
+	Subscription string `json:"subscription,omitempty"`
+}
+
+// DisplayName returns the label used for the config in lists and reports.
+// Unnamed configs are named after the host:port of their final hop.
+func (c *Config) DisplayName() string {
+	if name := strings.TrimSpace(c.Name); name != "" {
+		return name
+	}
+	parts := strings.Split(c.Transport, "|")
+	u, err := url.Parse(strings.TrimSpace(parts[len(parts)-1]))
+	if err == nil && u.Host != "" {
+		return u.Host
+	}
+	if sanitized, err := config.SanitizeConfig(c.Transport); err == nil {
+		return sanitized
+	}
+	return "invalid config"
 }
 
 // 0: healthly, 1: some tests failed, 2: all tests failed
@@ -47,7 +90,27 @@ type AppContext struct {
 	Settings    *AppSettings
 }
 
+// proxy is the running local proxy, or nil. The UI starts and stops it with
+// setRunningProxy, and proxyMutex guards it for other goroutines.
 var proxy *runningProxy
+var proxyMutex sync.Mutex
+
+// setRunningProxy sets the running local proxy, or nil once it is stopped.
+func setRunningProxy(p *runningProxy) {
+	proxyMutex.Lock()
+	defer proxyMutex.Unlock()
+	proxy = p
+}
+
+// runningProxyAddress returns the address of the running local proxy, or "".
+func runningProxyAddress() string {
+	proxyMutex.Lock()
+	defer proxyMutex.Unlock()
+	if proxy == nil {
+		return ""
+	}
+	return proxy.Address
+}
 
 func main() {
 	defer sysproxy.DisableWebProxy()
@@ -58,6 +121,8 @@ func main() {
 		app.SetMetadata(meta)
 	}
 
+	appVersion = formatAppVersion(ProxyApp.Metadata().Version, ProxyApp.Metadata().Build)
+
 	ProxyApp.Settings().SetTheme(newAppTheme())
 	mainWin := ProxyApp.NewWindow(ProxyApp.Metadata().Name)
 	mainWin.Resize(fyne.NewSize(200, 300))
@@ -73,6 +138,9 @@ func main() {
 	loadSettings(ctx)
 	printSettings(ctx)
 
+	// Retry uncollected reports in the background
+	go runOutboxRetry(ctx)
+
 	// State variable
 	state := &AppState{CurrentPage: "main"}
 
@@ -89,6 +157,20 @@ func main() {
 		}
 	}()
 
+	// Refresh subscriptions in the background, and merge and show what changed
+	go runSubscriptionRefresh(ctx, func(update subscriptionUpdate) {
+		diff, err := ctx.Settings.applySubscriptionUpdate(update)
+		if err != nil {
+			debugLog.Printf("Subscription refresh failed: %v", err)
+		}
+		ctx.Settings.forgetRemovedSelection()
+		updateSettings(ctx)
+		if diff.HasChanges() {
+			mainWin.SetContent(makePageContent(ctx, state, navChannel))
+			showImportSummary(ctx, "Subscription updated", diff.Summary())
+		}
+	})
+
 	// Set initial content
 	mainWin.SetContent(makePageContent(ctx, state, navChannel))
 	mainWin.ShowAndRun()
@@ -97,7 +179,8 @@ func main() {
 
 func loadSettings(ctx *AppContext) {
 	// Create your settings content here
-	var settings AppSettings
+	// Fields missing from saved settings keep these defaults
+	settings := AppSettings{ReportPrivacy: defaultReportPrivacy, ThroughputURL: defaultThroughputURL}
 	settingsJSON := ctx.Preferences.String("AppSettings")
 	if settingsJSON != "" {
 		err := json.Unmarshal([]byte(settingsJSON), &settings)
@@ -105,21 +188,34 @@ func loadSettings(ctx *AppContext) {
 			log.Println("Error loading settings:", err)
 		}
 		ctx.Settings = &settings
+		ensureConfigIDs(ctx.Settings.Configs)
 	} else {
 		// Set default settings if no saved settings are found
 		ctx.Settings = &AppSettings{
-			Domain:       "example.com",
-			ResolverHost: "8.8.8.8",
-			Tcp:          true,
-			Udp:          true,
-			LocalAddress: "localhost:8080",
+			Domain:        "example.com",
+			ResolverHost:  "8.8.8.8",
+			Tcp:           true,
+			Udp:           true,
+			LocalAddress:  "localhost:8080",
+			ReportPrivacy: defaultReportPrivacy,
+			ThroughputURL: defaultThroughputURL,
 		}
 	}
 }
 
+// marshalSettings serializes the settings while holding the locks of the
+// configs and the outbox, which other goroutines change.
+func marshalSettings(s *AppSettings) ([]byte, error) {
+	settingsMutex.RLock()
+	defer settingsMutex.RUnlock()
+	outboxMutex.Lock()
+	defer outboxMutex.Unlock()
+	return json.Marshal(s)
+}
+
 func updateSettings(ctx *AppContext) {
 	// Serialize settings to JSON
-	settingsJSON, err := json.Marshal(ctx.Settings)
+	settingsJSON, err := marshalSettings(ctx.Settings)
 	if err != nil {
 		log.Println("Error marshaling settings:", err)
 		return
@@ -130,7 +226,7 @@ func updateSettings(ctx *AppContext) {
 
 func printSettings(ctx *AppContext) {
 	// Serialize settings to JSON
-	settingsJSON, err := json.Marshal(ctx.Settings)
+	settingsJSON, err := marshalSettings(ctx.Settings)
 	if err != nil {
 		log.Println("Error marshaling settings:", err)
 		return
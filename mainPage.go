@@ -1,20 +1,27 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"image/color"
+	"io"
 	"log"
 	"net"
 	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+	"github.com/Jigsaw-Code/outline-sdk/x/config"
 	"github.com/Jigsaw-Code/outline-sdk/x/sysproxy"
 )
 
@@ -44,6 +51,8 @@ func makeMainPageContent(ctx *AppContext, navChannel chan NavEvent) fyne.CanvasO
 
 	list = widget.NewList(
 		func() int {
+			settingsMutex.RLock()
+			defer settingsMutex.RUnlock()
 			return len(ctx.Settings.Configs)
 		},
 		func() fyne.CanvasObject {
@@ -71,7 +80,13 @@ func makeMainPageContent(ctx *AppContext, navChannel chan NavEvent) fyne.CanvasO
 			label := container.Objects[2].(*widget.Label)
 			toolbar := container.Objects[4].(*widget.Toolbar)
 
-			switch ctx.Settings.Configs[i].Health {
+			// Actions refer to the config by ID, as a refresh may move it before they run
+			c, ok := ctx.Settings.configAt(i)
+			if !ok {
+				return
+			}
+			id := c.ID
+			switch c.Health {
 			case 0:
 				indicator.SetResource(theme.ViewRefreshIcon())
 			case 1:
@@ -84,14 +99,14 @@ func makeMainPageContent(ctx *AppContext, navChannel chan NavEvent) fyne.CanvasO
 				// all tests failed
 				indicator.SetResource(theme.ErrorIcon())
 			}
-			u, err := url.Parse(ctx.Settings.Configs[i].Transport)
-			if err != nil {
-				label.SetText("Parse error")
+			name := c.DisplayName()
+			if diagnosis := c.Diagnosis(); diagnosis != categoryNone {
+				label.SetText(name + " · " + diagnosis.Title())
 			} else {
-				label.SetText(u.Host)
+				label.SetText(name)
 			}
 
-			if i == selectedItemID {
+			if id == selectedConfigID {
 				// Set the selected item style
 				selected.FillColor = theme.PrimaryColor()
 			} else {
@@ -104,6 +119,7 @@ func makeMainPageContent(ctx *AppContext, navChannel chan NavEvent) fyne.CanvasO
 			arrowIcon := widget.NewToolbarAction(theme.NavigateNextIcon(), func() {
 				log.Printf("Next icon clicked for item %v", i)
 				// navigate to page result for specific menu item
+				resultConfigID = id
 				navChannel <- NavEvent{TargetPage: "configs"}
 				// Define action for the "+" icon
 			})
@@ -113,7 +129,8 @@ func makeMainPageContent(ctx *AppContext, navChannel chan NavEvent) fyne.CanvasO
 				callback := func(confirm bool) {
 					if confirm {
 						// Delete the item from the data slice
-						ctx.Settings.Configs = append(ctx.Settings.Configs[:i], ctx.Settings.Configs[i+1:]...)
+						ctx.Settings.removeConfig(id)
+						ctx.Settings.forgetRemovedSelection()
 						updateSettings(ctx)
 						// Refresh the list to update the view
 						list.Refresh()
@@ -123,6 +140,20 @@ func makeMainPageContent(ctx *AppContext, navChannel chan NavEvent) fyne.CanvasO
 				dialog := dialog.NewConfirm("Confirm Delete", "Sure to delete config?", callback, ctx.Window)
 				dialog.Show()
 			})
+			editIcon := widget.NewToolbarAction(theme.DocumentCreateIcon(), func() {
+				showEdit(ctx, id, list)
+			})
+			shareIcon := widget.NewToolbarAction(theme.MailForwardIcon(), func() {
+				showShare(ctx, id)
+			})
+			// Offer auto-tune only for configs with failing tests
+			if c.Health == 2 || c.Health == 3 {
+				toolbar.Append(widget.NewToolbarAction(theme.SearchReplaceIcon(), func() {
+					showAutoTune(ctx, id, list)
+				}))
+			}
+			toolbar.Append(shareIcon)
+			toolbar.Append(editIcon)
 			toolbar.Append(deleteIcon)
 			toolbar.Append(arrowIcon)
 		},
@@ -130,8 +161,10 @@ func makeMainPageContent(ctx *AppContext, navChannel chan NavEvent) fyne.CanvasO
 
 	list.OnSelected = func(id widget.ListItemID) {
 		//selectedItem := ctx.Settings.Configs[id].Transport
-		log.Printf("Selected Item ID %v", selectedItemID)
-		selectedItemID = id
+		log.Printf("Selected Item ID %v", id)
+		if c, ok := ctx.Settings.configAt(id); ok {
+			selectedConfigID = c.ID
+		}
 		list.Refresh()
 	}
 	// list.OnUnselected = func(o fyne.CanvasObject) {
@@ -160,6 +193,12 @@ func makeMainPageContent(ctx *AppContext, navChannel chan NavEvent) fyne.CanvasO
 
 	// Create the toolbar with a "+" icon
 	headerToolbarRight := widget.NewToolbar(
+		widget.NewToolbarAction(theme.MenuDropDownIcon(), func() {
+			log.Println("Sort by score clicked")
+			sortConfigsByScore(ctx.Settings)
+			updateSettings(ctx)
+			list.Refresh()
+		}),
 		widget.NewToolbarAction(theme.ContentAddIcon(), func() {
 			//addConfig(ctx)
 			//name := widget.NewEntry()
@@ -168,24 +207,58 @@ func makeMainPageContent(ctx *AppContext, navChannel chan NavEvent) fyne.CanvasO
 			inputURL.SetPlaceHolder("Enter config here")
 			paste := widget.NewToolbar(widget.NewToolbarAction(theme.ContentPasteIcon(), func() {
 				c := ctx.Window.Clipboard().Content()
-				inputURL.SetText(c)
+				if data, isImage, err := clipboardImage(c); err != nil {
+					log.Println("Error reading pasted image:", err)
+					dialog.ShowError(err, ctx.Window)
+				} else if isImage {
+					fillFromQRImage(ctx, inputURL, data)
+				} else {
+					inputURL.SetText(c)
+				}
 			}))
-			content := container.NewVBox(inputURL, paste)
-			dialog.ShowCustomConfirm("Add Config", "Add", "Cancel", content, func(confirm bool) {
+			fromImage := widget.NewButtonWithIcon("Import from image", theme.FileImageIcon(), func() {
+				showImportFromImage(ctx, inputURL)
+			})
+			var addDialog dialog.Dialog
+			fromFile := widget.NewButtonWithIcon("Import file…", theme.FolderOpenIcon(), func() {
+				addDialog.Hide()
+				showImportFile(ctx, list)
+			})
+			content := container.NewVBox(inputURL, container.NewHBox(paste, fromImage, fromFile))
+			addDialog = dialog.NewCustomConfirm("Add Config", "Add", "Cancel", content, func(confirm bool) {
 				if confirm {
-					configURLs, err := parseInputText(inputURL.Text)
+					if isSubscriptionURL(inputURL.Text) {
+						showAddSubscription(ctx, inputURL.Text, list)
+						return
+					}
+					f, err := ctx.Settings.configFetcher()
+					var configURLs []Config
 					if err == nil {
-						ctx.Settings.Configs = append(ctx.Settings.Configs, configURLs...)
-						updateSettings(ctx)
-					} else {
+						configURLs, err = parseInputText(inputURL.Text, f)
+					}
+					if err != nil {
 						log.Println("Error parsing clipboard content:", err)
+						dialog.ShowError(err, ctx.Window)
+						return
 					}
-					list.Refresh()
+					addImportedConfigs(ctx, configURLs, list, func(result dedupeResult) {
+						if result.Added < len(configURLs) {
+							showImportSummary(ctx, "Add Config", result.Summary())
+						}
+					})
 				}
 			}, ctx.Window)
+			addDialog.Show()
 		}),
 	)
 
+	// Files dropped on the window are imported like "Import file…"
+	ctx.Window.SetOnDropped(func(_ fyne.Position, uris []fyne.URI) {
+		for _, uri := range uris {
+			importFileURI(ctx, uri, list)
+		}
+	})
+
 	// Create the toolbar with settings icon
 	headerToolbarLeft := widget.NewToolbar(
 		widget.NewToolbarAction(theme.SettingsIcon(), func() {
@@ -194,6 +267,18 @@ func makeMainPageContent(ctx *AppContext, navChannel chan NavEvent) fyne.CanvasO
 			// myWindow.SetContent(makeSettingsPageContent())
 			// Define action for the "+" icon
 		}),
+		widget.NewToolbarAction(theme.SearchIcon(), func() {
+			log.Println("Compare icon clicked")
+			navChannel <- NavEvent{TargetPage: "compare"}
+		}),
+		widget.NewToolbarAction(theme.DocumentSaveIcon(), func() {
+			log.Println("Export icon clicked")
+			showExportReports(ctx)
+		}),
+		widget.NewToolbarAction(theme.UploadIcon(), func() {
+			log.Println("Export configs icon clicked")
+			showExportConfigs(ctx)
+		}),
 	)
 
 	header := makePageHeader("Proxy App", headerToolbarLeft, headerToolbarRight)
@@ -226,8 +311,33 @@ func makeMainPageContent(ctx *AppContext, navChannel chan NavEvent) fyne.CanvasO
 
 	ConnectButton.OnTapped = func() {
 		log.Println(ConnectButton.Text)
-		TestSingleConfig(ctx.Settings, selectedItemID)
-		sumbitOneReport(ctx.Settings, selectedItemID)
+		if proxy == nil && ctx.Settings.AutoSelect {
+			// Connect with the top-scoring config that passed its last test
+			configs := ctx.Settings.configsSnapshot()
+			best, ok := bestHealthyConfig(configs)
+			if !ok {
+				setProxyUI(nil, errors.New("no config passed its last test, run Test All first"))
+				return
+			}
+			selectedConfigID = configs[best].ID
+			log.Printf("Auto selected config %v", configs[best].DisplayName())
+		}
+		id := selectedConfigID
+		if proxy == nil {
+			if _, ok := ctx.Settings.configByID(id); !ok {
+				setProxyUI(nil, errors.New("select a config to connect with"))
+				return
+			}
+			// Dynamic access keys may have been rotated by the provider
+			if err := ctx.Settings.refreshDynamicKeyOf(id); err != nil {
+				log.Println("Error refreshing dynamic access key:", err)
+			}
+		}
+		if err := TestSingleConfig(ctx.Settings, id); err != nil {
+			log.Println("Error testing config:", err)
+		}
+		sumbitOneReport(ctx.Settings, ctx.Settings.reportSettings(), id)
+		updateSettings(ctx)
 		list.Refresh()
 		var err error
 		//systemProxy, err := GetSystemProxy()
@@ -236,10 +346,13 @@ func makeMainPageContent(ctx *AppContext, navChannel chan NavEvent) fyne.CanvasO
 		// }
 		if proxy == nil {
 			// Start proxy.
+			c, _ := ctx.Settings.configByID(id)
 			log.Printf("Starting proxy on %v", ctx.Settings.LocalAddress)
-			log.Printf("Using config: %v", ctx.Settings.Configs[selectedItemID].Transport)
-			if ctx.Settings.Configs[selectedItemID].Health == 1 {
-				proxy, err = runServer(ctx.Settings.LocalAddress, ctx.Settings.Configs[selectedItemID].Transport)
+			log.Printf("Using config: %v", c.DisplayName())
+			if c.Health == 1 {
+				var running *runningProxy
+				running, err = runServer(ctx.Settings.LocalAddress, c.Transport)
+				setRunningProxy(running)
 				if err != nil {
 					// TODO: show error in GUI / Handle error
 					fmt.Println("Error starting proxy:", err)
@@ -255,7 +368,7 @@ func makeMainPageContent(ctx *AppContext, navChannel chan NavEvent) fyne.CanvasO
 				}
 			} else {
 				err = errors.New("could not connect to remote destination")
-				proxy = nil
+				setRunningProxy(nil)
 			}
 		} else {
 			// Stop proxy
@@ -265,12 +378,18 @@ func makeMainPageContent(ctx *AppContext, navChannel chan NavEvent) fyne.CanvasO
 			} else {
 				fmt.Println("Proxy unset successful")
 			}
-			proxy = nil
+			setRunningProxy(nil)
 		}
 		setProxyUI(proxy, err)
 	}
 	setProxyUI(proxy, nil)
 
+	autoCheck := widget.NewCheck("Auto (connect with the top-scoring working config)", func(value bool) {
+		ctx.Settings.AutoSelect = value
+		updateSettings(ctx)
+	})
+	autoCheck.Checked = ctx.Settings.AutoSelect
+
 	buttonState := make(chan bool)
 	TestButton := widget.NewButton("Test All", func() {
 		go func() {
@@ -278,10 +397,15 @@ func makeMainPageContent(ctx *AppContext, navChannel chan NavEvent) fyne.CanvasO
 			buttonState <- true
 
 			// test all configs
-			TestConfigs(ctx.Settings)
+			if err := TestConfigs(ctx.Settings); err != nil {
+				log.Println("Error testing configs:", err)
+			}
 			updateSettings(ctx)
-			log.Printf("Test reports: %v", ctx.Settings.Configs)
-			go submitReports(ctx.Settings)
+			go func() {
+				submitReports(ctx.Settings)
+				// Persist reports queued in the outbox
+				updateSettings(ctx)
+			}()
 			list.Refresh()
 
 			// Re-enable the button and reset text in the main goroutine
@@ -311,7 +435,523 @@ func makeMainPageContent(ctx *AppContext, navChannel chan NavEvent) fyne.CanvasO
 		header,
 		listWithMaxHeight, // The scrollable list with enforced maximum height
 		progressBar,
+		autoCheck,
 		container.New(layout.NewGridLayoutWithColumns(2), TestButton, ConnectButton),
 		statusBox,
 	)
 }
+
+// isSubscriptionURL reports whether the input is a config list URL to subscribe to.
+func isSubscriptionURL(input string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(input)), "https://")
+}
+
+// showAddSubscription asks for the optional request headers of the config list
+// at subscriptionURL, subscribes to it and shows which configs were added or removed.
+func showAddSubscription(ctx *AppContext, subscriptionURL string, list *widget.List) {
+	// The URL itself may hold a token, so only its host is shown
+	host := subscriptionURL
+	if u, err := url.Parse(strings.TrimSpace(subscriptionURL)); err == nil {
+		host = u.Host
+	}
+	headersEntry := newHeadersEntry(nil)
+	headersItem := widget.NewFormItem("Headers", headersEntry)
+	headersItem.HintText = "Optional, sent only to this host"
+	form := []*widget.FormItem{widget.NewFormItem("Host", widget.NewLabel(host)), headersItem}
+	dialog.ShowForm("Add Subscription", "Subscribe", "Cancel", form, func(confirm bool) {
+		if !confirm {
+			return
+		}
+		headers, _ := parseHeaderLines(splitLines(headersEntry.Text))
+		diff, err := ctx.Settings.AddSubscription(subscriptionURL, headers)
+		if err != nil {
+			log.Println("Error adding subscription:", err)
+			dialog.ShowError(err, ctx.Window)
+			return
+		}
+		ctx.Settings.forgetRemovedSelection()
+		updateSettings(ctx)
+		list.Refresh()
+		showImportSummary(ctx, "Subscription", diff.Summary())
+	}, ctx.Window)
+}
+
+// showImportSummary shows the outcome of an import, which may list many rejected lines.
+func showImportSummary(ctx *AppContext, title string, summary string) {
+	label := widget.NewLabel(summary)
+	label.Wrapping = fyne.TextWrapWord
+	scroll := container.NewVScroll(label)
+	scroll.SetMinSize(fyne.NewSize(400, 250))
+	dialog.ShowCustom(title, "OK", scroll, ctx.Window)
+}
+
+// showImportFile asks for a config file and imports it.
+func showImportFile(ctx *AppContext, list *widget.List) {
+	dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil || reader == nil {
+			return
+		}
+		defer reader.Close()
+		importFile(ctx, reader.URI().Name(), reader, list)
+	}, ctx.Window)
+}
+
+// importFileURI imports the config file at uri, such as a file dropped on the window.
+func importFileURI(ctx *AppContext, uri fyne.URI, list *widget.List) {
+	reader, err := storage.Reader(uri)
+	if err != nil {
+		log.Println("Error opening dropped file:", err)
+		dialog.ShowError(err, ctx.Window)
+		return
+	}
+	defer reader.Close()
+	importFile(ctx, uri.Name(), reader, list)
+}
+
+// importFile adds the configs of a config file or QR code image and shows
+// which entries were accepted and rejected.
+func importFile(ctx *AppContext, name string, r io.Reader, list *widget.List) {
+	configs, result, err := importConfigFile(name, r)
+	if err != nil {
+		log.Println("Error importing file:", err)
+		if result == nil {
+			dialog.ShowError(err, ctx.Window)
+			return
+		}
+		showImportSummary(ctx, "Import file", err.Error()+"\n\n"+fileImportSummary(name, nil, result))
+		return
+	}
+	log.Printf("Imported %d configs from a %v file, rejected %d", len(configs), result.Format, len(result.Rejected))
+	addImportedConfigs(ctx, configs, list, func(added dedupeResult) {
+		showImportSummary(ctx, "Import file", fileImportSummary(name, configs, result)+"\n\n"+added.Summary())
+	})
+}
+
+// addImportedConfigs adds imported configs to the list. If some of them are
+// already in it, it first asks whether to merge, skip or replace them. done
+// receives the outcome once the configs are added.
+func addImportedConfigs(ctx *AppContext, imported []Config, list *widget.List, done func(dedupeResult)) {
+	add := func(policy duplicatePolicy) {
+		var result dedupeResult
+		settingsMutex.Lock()
+		ctx.Settings.Configs, result = mergeImportedConfigs(ctx.Settings.Configs, imported, policy)
+		settingsMutex.Unlock()
+		log.Printf("Added %d configs, %d duplicates handled by %v", result.Added, len(imported)-result.Added, policy)
+		updateSettings(ctx)
+		list.Refresh()
+		done(result)
+	}
+	duplicates := countDuplicates(ctx.Settings.configsSnapshot(), imported)
+	if duplicates == 0 {
+		add(duplicateMerge)
+		return
+	}
+	options := []string{
+		"Merge: keep their names and test reports",
+		"Skip: leave them unchanged",
+		"Replace: use the imported configs",
+	}
+	policies := map[string]duplicatePolicy{
+		options[0]: duplicateMerge,
+		options[1]: duplicateSkip,
+		options[2]: duplicateReplace,
+	}
+	choice := widget.NewRadioGroup(options, nil)
+	choice.SetSelected(options[0])
+	choice.Required = true
+	message := widget.NewLabel(fmt.Sprintf("%d of the %d imported configs are already in the list.", duplicates, len(imported)))
+	dialog.ShowCustomConfirm("Duplicate Configs", "Import", "Cancel", container.NewVBox(message, choice), func(confirm bool) {
+		if confirm {
+			add(policies[choice.Selected])
+		}
+	}, ctx.Window)
+}
+
+// showImportFromImage asks for a PNG or JPEG image and puts the text of its
+// QR code into input.
+func showImportFromImage(ctx *AppContext, input *widget.Entry) {
+	openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil || reader == nil {
+			return
+		}
+		defer reader.Close()
+		data, err := readImage(reader)
+		if err != nil {
+			log.Println("Error reading image:", err)
+			dialog.ShowError(err, ctx.Window)
+			return
+		}
+		fillFromQRImage(ctx, input, data)
+	}, ctx.Window)
+	openDialog.SetFilter(storage.NewExtensionFileFilter([]string{".png", ".jpg", ".jpeg"}))
+	openDialog.Show()
+}
+
+// fillFromQRImage decodes the QR code of an image into input, so that Add
+// parses it like a pasted config.
+func fillFromQRImage(ctx *AppContext, input *widget.Entry, data []byte) {
+	text, err := decodeQRImage(data)
+	if err != nil {
+		log.Println("Error decoding QR code:", err)
+		dialog.ShowError(err, ctx.Window)
+		return
+	}
+	input.SetText(text)
+}
+
+// showEdit lets the user edit the name and transport of the config with the
+// given ID. A Shadowsocks final hop also gets a form for its fields, kept in
+// sync with the transport, which is validated as it is edited.
+func showEdit(ctx *AppContext, id string, list *widget.List) {
+	original, ok := ctx.Settings.configByID(id)
+	if !ok {
+		return
+	}
+	name := widget.NewEntry()
+	name.SetText(original.Name)
+	name.SetPlaceHolder(original.DisplayName())
+	transportEntry := widget.NewMultiLineEntry()
+	transportEntry.Wrapping = fyne.TextWrapBreak
+	transportEntry.SetText(original.Transport)
+
+	host := widget.NewEntry()
+	port := widget.NewEntry()
+	cipher := widget.NewSelect(append([]string{}, shadowsocksCiphers...), nil)
+	password := widget.NewPasswordEntry()
+	prefix := widget.NewEntry()
+	prefix.SetPlaceHolder("Optional, %XX for bytes")
+	ssForm := widget.NewForm(
+		widget.NewFormItem("Host", host),
+		widget.NewFormItem("Port", port),
+		widget.NewFormItem("Cipher", cipher),
+		widget.NewFormItem("Password", password),
+		widget.NewFormItem("Prefix", prefix),
+	)
+
+	status := widget.NewLabel("")
+	status.Wrapping = fyne.TextWrapWord
+	status.Importance = widget.DangerImportance
+	status.Hide()
+	save := widget.NewButtonWithIcon("Save", theme.DocumentSaveIcon(), nil)
+	save.Importance = widget.HighImportance
+	showStatus := func(err error) {
+		if err != nil {
+			status.SetText(err.Error())
+			status.Show()
+			save.Disable()
+			return
+		}
+		status.Hide()
+		save.Enable()
+	}
+
+	// syncing keeps the transport and the Shadowsocks form from updating each other in a loop
+	syncing := false
+	// ssChain and ssServer are the hops before the Shadowsocks server and the server itself
+	var ssChain string
+	var ssServer *ServerInfo
+	fillForm := func() {
+		var ok bool
+		ssChain, ssServer, ok = splitShadowsocksHop(transportEntry.Text)
+		if !ok {
+			ssForm.Hide()
+			return
+		}
+		syncing = true
+		host.SetText(ssServer.Server)
+		port.SetText(strconv.Itoa(ssServer.ServerPort))
+		if !slices.Contains(cipher.Options, ssServer.Method) {
+			cipher.Options = append(cipher.Options, ssServer.Method)
+		}
+		cipher.SetSelected(ssServer.Method)
+		password.SetText(ssServer.Password)
+		prefix.SetText(escapePrefix(ssServer.Prefix))
+		syncing = false
+		ssForm.Show()
+	}
+	updateTransport := func() {
+		if syncing || ssServer == nil {
+			return
+		}
+		server := *ssServer
+		server.Server = strings.TrimSpace(host.Text)
+		server.Method = cipher.Selected
+		server.Password = password.Text
+		var err error
+		if server.ServerPort, err = parseServerPort(port.Text); err == nil {
+			server.Prefix, err = unescapePrefix(prefix.Text)
+		}
+		var transportConfig string
+		if err == nil {
+			transportConfig, err = joinShadowsocksHop(ssChain, &server)
+		}
+		if err != nil {
+			showStatus(err)
+			return
+		}
+		syncing = true
+		transportEntry.SetText(transportConfig)
+		syncing = false
+		showStatus(validateEditedTransport(transportConfig))
+	}
+	transportEntry.OnChanged = func(string) {
+		if syncing {
+			return
+		}
+		showStatus(validateEditedTransport(transportEntry.Text))
+		fillForm()
+	}
+	for _, entry := range []*widget.Entry{host, port, password, prefix} {
+		entry.OnChanged = func(string) { updateTransport() }
+	}
+	cipher.OnChanged = func(string) { updateTransport() }
+	fillForm()
+	showStatus(validateEditedTransport(transportEntry.Text))
+
+	content := container.NewVBox(
+		widget.NewForm(widget.NewFormItem("Name", name), widget.NewFormItem("Transport", transportEntry)),
+		ssForm,
+		status,
+	)
+	editDialog := dialog.NewCustomWithoutButtons("Edit Config", content, ctx.Window)
+	save.OnTapped = func() {
+		// The config may have been refreshed or removed while the dialog was open
+		var err error
+		changed := false
+		found := ctx.Settings.updateConfig(id, func(c *Config) {
+			if c.Transport != original.Transport {
+				changed = true
+				return
+			}
+			err = c.Edit(name.Text, transportEntry.Text)
+		})
+		if !found || changed {
+			editDialog.Hide()
+			dialog.ShowError(fmt.Errorf("the config was changed or removed meanwhile"), ctx.Window)
+			return
+		}
+		if err != nil {
+			showStatus(err)
+			return
+		}
+		log.Printf("Edited config %v", name.Text)
+		updateSettings(ctx)
+		list.Refresh()
+		editDialog.Hide()
+	}
+	editDialog.SetButtons([]fyne.CanvasObject{
+		widget.NewButtonWithIcon("Cancel", theme.CancelIcon(), func() { editDialog.Hide() }),
+		save,
+	})
+	editDialog.Resize(fyne.NewSize(500, 0))
+	editDialog.Show()
+}
+
+// showShare shows the share link and QR code of the config with the given ID,
+// after a warning if they carry its credentials.
+func showShare(ctx *AppContext, id string) {
+	c, ok := ctx.Settings.configByID(id)
+	if !ok {
+		return
+	}
+	if !linkHasSecrets(c.ShareLink(false)) {
+		showShareCode(ctx, c)
+		return
+	}
+	message := "The QR code and link contain the password of this config.\nAnyone who sees them can use the server.\n\nShow them?"
+	dialog.ShowConfirm("Share Config", message, func(confirm bool) {
+		if confirm {
+			showShareCode(ctx, c)
+		}
+	}, ctx.Window)
+}
+
+// showShareCode shows the share link of c as a QR code with buttons to copy
+// the link and to save the code as a PNG file.
+func showShareCode(ctx *AppContext, c Config) {
+	qrImage := canvas.NewImageFromResource(nil)
+	qrImage.FillMode = canvas.ImageFillContain
+	qrImage.SetMinSize(fyne.NewSize(256, 256))
+	link := widget.NewLabel("")
+	link.Wrapping = fyne.TextWrapBreak
+	var png []byte
+	update := func(withName bool) {
+		text := c.ShareLink(withName)
+		data, err := shareQRCode(text)
+		if err != nil {
+			log.Println("Error encoding QR code:", err)
+			dialog.ShowError(err, ctx.Window)
+			return
+		}
+		png = data
+		link.SetText(text)
+		qrImage.Resource = fyne.NewStaticResource(shareFileName(&c), data)
+		qrImage.Refresh()
+	}
+	withName := widget.NewCheck("Include name", nil)
+	withName.SetChecked(c.Name != "")
+	withName.OnChanged = update
+	if c.Name == "" {
+		withName.Disable()
+	}
+	update(withName.Checked)
+
+	copyLink := widget.NewButtonWithIcon("Copy link", theme.ContentCopyIcon(), func() {
+		ctx.Window.Clipboard().SetContent(link.Text)
+	})
+	savePNG := widget.NewButtonWithIcon("Save PNG", theme.DocumentSaveIcon(), func() {
+		saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+			if err != nil || writer == nil {
+				return
+			}
+			defer writer.Close()
+			if _, err := writer.Write(png); err != nil {
+				log.Println("Error saving QR code:", err)
+				dialog.ShowError(err, ctx.Window)
+			}
+		}, ctx.Window)
+		saveDialog.SetFileName(shareFileName(&c))
+		saveDialog.Show()
+	})
+	content := container.NewVBox(qrImage, link, withName, container.NewHBox(copyLink, savePNG))
+	dialog.ShowCustom("Share "+c.DisplayName(), "Close", content, ctx.Window)
+}
+
+// showAutoTune searches for a working transport chain for the config with the
+// given ID and offers to save the best one as a new config.
+func showAutoTune(ctx *AppContext, id string, list *widget.List) {
+	progress := dialog.NewCustomWithoutButtons("Auto-tune", container.NewVBox(
+		widget.NewLabel("Trying split, tlsfrag and prefix variants..."),
+		widget.NewProgressBarInfinite(),
+	), ctx.Window)
+	progress.Show()
+	go func() {
+		working, err := AutoTune(ctx.Settings, id)
+		progress.Hide()
+		if err != nil {
+			dialog.ShowError(err, ctx.Window)
+			return
+		}
+		if len(working) == 0 {
+			dialog.ShowInformation("Auto-tune", "No working transport chain found", ctx.Window)
+			return
+		}
+		best := working[0]
+		sanitized, err := config.SanitizeConfig(best.Transport)
+		if err != nil {
+			sanitized = best.Transport
+		}
+		message := fmt.Sprintf("Found %d working chains. Best:\n%v\n\nSave it as a new config?", len(working), sanitized)
+		dialog.ShowConfirm("Auto-tune", message, func(confirm bool) {
+			if confirm {
+				ctx.Settings.addConfigs(best)
+				updateSettings(ctx)
+				list.Refresh()
+			}
+		}, ctx.Window)
+	}()
+}
+
+// showExportReports asks for a format and a file and exports all test reports to it.
+func showExportReports(ctx *AppContext) {
+	formats := map[string]ReportFormat{"JSON Lines": FormatJSONLines, "CSV": FormatCSV}
+	formatRadio := widget.NewRadioGroup([]string{"JSON Lines", "CSV"}, nil)
+	formatRadio.Required = true
+	formatRadio.SetSelected("JSON Lines")
+	dialog.ShowCustomConfirm("Export reports", "Export", "Cancel", formatRadio, func(confirm bool) {
+		if !confirm {
+			return
+		}
+		format := formats[formatRadio.Selected]
+		// The reports are exported under the lock before the file is created
+		var exported bytes.Buffer
+		settingsMutex.RLock()
+		err := ExportReports(&exported, ctx.Settings.Configs, format)
+		settingsMutex.RUnlock()
+		if err != nil {
+			log.Println("Error exporting reports:", err)
+			dialog.ShowError(err, ctx.Window)
+			return
+		}
+		saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+			if err != nil || writer == nil {
+				return
+			}
+			defer writer.Close()
+			if _, err := writer.Write(exported.Bytes()); err != nil {
+				log.Println("Error exporting reports:", err)
+				dialog.ShowError(err, ctx.Window)
+			}
+		}, ctx.Window)
+		saveDialog.SetFileName("reports." + string(format))
+		saveDialog.Show()
+	}, ctx.Window)
+}
+
+// showExportConfigs asks which configs to export, filtered by health, and in
+// which format, and saves them to a file.
+func showExportConfigs(ctx *AppContext) {
+	scopeRadio := widget.NewRadioGroup([]string{"Selected config", "All configs"}, nil)
+	scopeRadio.SetSelected("All configs")
+	healths := map[string]int{"Passed": 1, "Some tests failed": 2, "Failed": 3, "Not tested": 0}
+	healthChecks := widget.NewCheckGroup([]string{"Passed", "Some tests failed", "Failed", "Not tested"}, nil)
+	healthChecks.SetSelected(healthChecks.Options)
+	formats := map[string]ConfigFormat{"SIP008 JSON": ConfigFormatSIP008, "Base64 subscription": ConfigFormatBase64, "Plain list": ConfigFormatList}
+	formatRadio := widget.NewRadioGroup([]string{"SIP008 JSON", "Base64 subscription", "Plain list"}, nil)
+	formatRadio.Required = true
+	formatRadio.SetSelected("Base64 subscription")
+	form := []*widget.FormItem{
+		widget.NewFormItem("Configs", scopeRadio),
+		widget.NewFormItem("Health", healthChecks),
+		widget.NewFormItem("Format", formatRadio),
+	}
+	dialog.ShowForm("Export configs", "Export", "Cancel", form, func(confirm bool) {
+		if !confirm {
+			return
+		}
+		configs := ctx.Settings.configsSnapshot()
+		if scopeRadio.Selected == "Selected config" {
+			selected, ok := ctx.Settings.configByID(selectedConfigID)
+			if !ok {
+				dialog.ShowInformation("Export configs", "No config selected", ctx.Window)
+				return
+			}
+			configs = []Config{selected}
+		}
+		var selectedHealths []int
+		for _, option := range healthChecks.Selected {
+			selectedHealths = append(selectedHealths, healths[option])
+		}
+		configs = filterConfigsByHealth(configs, selectedHealths)
+		if len(configs) == 0 {
+			dialog.ShowInformation("Export configs", "No configs match the selected health", ctx.Window)
+			return
+		}
+		format := formats[formatRadio.Selected]
+		// The configs are exported before the file is created, so that a
+		// failed export leaves no empty file behind
+		var exported bytes.Buffer
+		skipped, err := ExportConfigs(&exported, configs, format)
+		if err != nil {
+			log.Println("Error exporting configs:", err)
+			dialog.ShowError(err, ctx.Window)
+			return
+		}
+		saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+			if err != nil || writer == nil {
+				return
+			}
+			defer writer.Close()
+			if _, err := writer.Write(exported.Bytes()); err != nil {
+				log.Println("Error exporting configs:", err)
+				dialog.ShowError(err, ctx.Window)
+				return
+			}
+			if len(skipped) > 0 {
+				showImportSummary(ctx, "Export configs", "SIP008 only holds Shadowsocks servers. Not exported:\n"+strings.Join(skipped, "\n"))
+			}
+		}, ctx.Window)
+		saveDialog.SetFileName(format.FileName())
+		saveDialog.Show()
+	}, ctx.Window)
+}
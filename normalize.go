@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// defaultPorts are the ports that hops of a scheme use when they leave theirs out.
+var defaultPorts = map[string]string{
+	"socks5": "1080",
+}
+
+// normalizeTransport returns the canonical form of a transport, so that the same
+// server written in different ways compares equal: Shadowsocks hops in SIP002
+// form with base64url userinfo, lower-cased hosts, default ports written out and
+// query parameters sorted. Hops that do not parse are kept as they are.
+func normalizeTransport(transportConfig string) string {
+	parts := strings.Split(strings.TrimSpace(transportConfig), "|")
+	for i, part := range parts {
+		parts[i] = normalizeHop(strings.TrimSpace(part))
+	}
+	return strings.Join(parts, "|")
+}
+
+// shadowsocksParams are the query parameters that makeShadowsocksURLfromJSON
+// writes back. Shadowsocks hops with any other parameter are not normalized,
+// so that no parameter is lost.
+var shadowsocksParams = map[string]bool{"prefix": true, "plugin": true}
+
+// normalizeHop returns the canonical form of one element of a transport chain.
+func normalizeHop(hop string) string {
+	if strings.HasPrefix(strings.ToLower(hop), "ss://") {
+		server, err := parseShadowsocksURL(hop)
+		if err != nil || !hasOnlyShadowsocksParams(hop) {
+			return hop
+		}
+		server.Server = strings.ToLower(server.Server)
+		server.Remarks, server.ID = "", ""
+		normalized, err := makeShadowsocksURLfromJSON(server)
+		if err != nil {
+			return hop
+		}
+		return normalized
+	}
+	u, err := url.Parse(hop)
+	if err != nil || u.Scheme == "" {
+		return hop
+	}
+	if u.Host != "" {
+		host, port := strings.ToLower(u.Hostname()), u.Port()
+		if port == "" {
+			port = defaultPorts[u.Scheme]
+		}
+		switch {
+		case port != "":
+			u.Host = net.JoinHostPort(host, port)
+		case strings.Contains(host, ":"):
+			u.Host = "[" + host + "]"
+		default:
+			u.Host = host
+		}
+	}
+	if u.RawQuery != "" {
+		// Parameters are sorted as written, as re-encoding them could change their bytes
+		params := strings.Split(u.RawQuery, "&")
+		sort.Strings(params)
+		u.RawQuery = strings.Join(params, "&")
+	}
+	return u.String()
+}
+
+// hasOnlyShadowsocksParams reports whether the query of a Shadowsocks hop
+// holds only single shadowsocksParams.
+func hasOnlyShadowsocksParams(hop string) bool {
+	_, rawQuery, _ := strings.Cut(hop, "?")
+	rawQuery, _, _ = strings.Cut(rawQuery, "#")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return false
+	}
+	for key, values := range query {
+		if !shadowsocksParams[key] || len(values) > 1 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeTransport(t *testing.T) {
+	tests := []struct {
+		name      string
+		transport string
+		want      string
+	}{
+		{"canonical", testSSKey, testSSKey},
+		{"padded std base64 and upper-case host", "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpzZWNyZXQ=@Example.COM:443", testSSKey},
+		{"plain userinfo", "ss://chacha20-ietf-poly1305:secret@example.com:443", testSSKey},
+		{"legacy", "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpzZWNyZXRAZXhhbXBsZS5jb206NDQz", testSSKey},
+		{"blanks", "  " + testSSKey + "\n", testSSKey},
+		{"chain", "split:3 | ss://chacha20-ietf-poly1305:secret@EXAMPLE.com:443", "split:3|" + testSSKey},
+		{"socks5 default port", "socks5://Proxy.Example.com", "socks5://proxy.example.com:1080"},
+		{"socks5 explicit port", "socks5://proxy.example.com:1081", "socks5://proxy.example.com:1081"},
+		{"IPv6 host", "socks5://[2001:DB8::1]", "socks5://[2001:db8::1]:1080"},
+		{"sorted query", "ws://example.com/?tcp_path=%2Fa&udp_path=%2Fb", "ws://example.com/?tcp_path=%2Fa&udp_path=%2Fb"},
+		{"unsorted query", "ws://example.com/?udp_path=%2Fb&tcp_path=%2Fa", "ws://example.com/?tcp_path=%2Fa&udp_path=%2Fb"},
+		{"opaque", "tls:sni=example.com", "tls:sni=example.com"},
+		{"invalid ss kept", "ss://notbase64@example.com:443", "ss://notbase64@example.com:443"},
+		{"unknown ss parameter kept", "ss://chacha20-ietf-poly1305:secret@Example.com:443/?prefix=x&outline=1", "ss://chacha20-ietf-poly1305:secret@Example.com:443/?prefix=x&outline=1"},
+		{"repeated ss parameter kept", "ss://chacha20-ietf-poly1305:secret@example.com:443/?prefix=a&prefix=b", "ss://chacha20-ietf-poly1305:secret@example.com:443/?prefix=a&prefix=b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := normalizeTransport(tt.transport)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, got, normalizeTransport(got), "not idempotent")
+		})
+	}
+}
+
+func TestNormalizeTransportPrefix(t *testing.T) {
+	transport := "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpzZWNyZXQ@b.example.com:8388/?prefix=POST%20"
+	normalized := normalizeTransport(transport)
+	require.NoError(t, validateTransport(normalized))
+	server, err := parseShadowsocksURL(normalized)
+	require.NoError(t, err)
+	assert.Equal(t, "POST ", server.Prefix)
+}
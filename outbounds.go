@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+)
+
+// nonProxyOutbounds are outbound types that route traffic without a proxy
+// server. They are left out of imports without being reported.
+var nonProxyOutbounds = map[string]bool{
+	// sing-box
+	"direct": true, "block": true, "dns": true, "selector": true, "urltest": true,
+	// v2ray and xray
+	"freedom": true, "blackhole": true, "loopback": true,
+}
+
+// singBoxOutbound is an outbound of a sing-box config.
+// https://sing-box.sagernet.org/configuration/outbound/
+type singBoxOutbound struct {
+	Type       string `json:"type"`
+	Tag        string `json:"tag"`
+	Server     string `json:"server"`
+	ServerPort int    `json:"server_port"`
+	Method     string `json:"method"`
+	Password   string `json:"password"`
+	Plugin     string `json:"plugin"`
+	Version    string `json:"version"`
+	Username   string `json:"username"`
+}
+
+// v2rayOutbound is an outbound of a v2ray or xray config.
+// https://xtls.github.io/en/config/outbound.html
+type v2rayOutbound struct {
+	Protocol string `json:"protocol"`
+	Tag      string `json:"tag"`
+	Settings struct {
+		Servers []v2rayServer `json:"servers"`
+	} `json:"settings"`
+}
+
+// v2rayServer is a server of a v2ray shadowsocks or socks outbound.
+type v2rayServer struct {
+	Address  string `json:"address"`
+	Port     int    `json:"port"`
+	Method   string `json:"method"`
+	Password string `json:"password"`
+	Users    []struct {
+		User string `json:"user"`
+		Pass string `json:"pass"`
+	} `json:"users"`
+}
+
+// parseOutbounds imports the shadowsocks and socks outbounds of a sing-box or
+// v2ray config. The format is told apart by the "type" key of sing-box
+// outbounds and the "protocol" key of v2ray outbounds.
+func parseOutbounds(outbounds []json.RawMessage) (*importResult, error) {
+	var result *importResult
+	for _, raw := range outbounds {
+		var probe struct {
+			Type     string `json:"type"`
+			Protocol string `json:"protocol"`
+		}
+		if json.Unmarshal(raw, &probe) != nil {
+			continue
+		}
+		if probe.Protocol != "" {
+			result = &importResult{Format: formatV2Ray}
+			break
+		}
+		if probe.Type != "" {
+			result = &importResult{Format: formatSingBox}
+			break
+		}
+	}
+	if result == nil {
+		return nil, fmt.Errorf("unknown outbounds format")
+	}
+	for i, raw := range outbounds {
+		item := fmt.Sprintf("outbound %d", i+1)
+		var err error
+		if result.Format == formatV2Ray {
+			err = result.addV2RayOutbound(item, raw)
+		} else {
+			err = result.addSingBoxOutbound(raw)
+		}
+		if err != nil {
+			result.reject(item, err)
+		}
+	}
+	return result, nil
+}
+
+// addSingBoxOutbound imports one sing-box outbound.
+func (r *importResult) addSingBoxOutbound(raw json.RawMessage) error {
+	var outbound singBoxOutbound
+	if err := json.Unmarshal(raw, &outbound); err != nil {
+		return fmt.Errorf("invalid outbound")
+	}
+	var configURL string
+	var err error
+	switch {
+	case nonProxyOutbounds[outbound.Type]:
+		return nil
+	case outbound.Type == "shadowsocks" && outbound.Plugin != "":
+		// outline-sdk dials Shadowsocks without plugins and would fail to reach the server.
+		return fmt.Errorf("ss plugin %v is not supported", outbound.Plugin)
+	case outbound.Type == "shadowsocks":
+		configURL, err = makeShadowsocksURLfromJSON(&ServerInfo{
+			Remarks:    outbound.Tag,
+			Server:     outbound.Server,
+			ServerPort: outbound.ServerPort,
+			Method:     outbound.Method,
+			Password:   outbound.Password,
+		})
+	case outbound.Type == "socks" && (outbound.Version == "" || outbound.Version == "5"):
+		configURL, err = makeSocks5URL(outbound.Server, outbound.ServerPort, outbound.Username, outbound.Password, outbound.Tag)
+	case outbound.Type == "socks":
+		return fmt.Errorf("SOCKS version %v is not supported", outbound.Version)
+	default:
+		r.skip(outbound.Type)
+		return nil
+	}
+	if err == nil {
+		err = validateTransport(configURL)
+	}
+	if err != nil {
+		return err
+	}
+	r.Accepted = append(r.Accepted, configURL)
+	return nil
+}
+
+// addV2RayOutbound imports every server of one v2ray outbound. Outbounds with
+// several servers name them after the tag and the server number.
+func (r *importResult) addV2RayOutbound(item string, raw json.RawMessage) error {
+	var outbound v2rayOutbound
+	if err := json.Unmarshal(raw, &outbound); err != nil {
+		return fmt.Errorf("invalid outbound")
+	}
+	if nonProxyOutbounds[outbound.Protocol] {
+		return nil
+	}
+	if outbound.Protocol != "shadowsocks" && outbound.Protocol != "socks" {
+		r.skip(outbound.Protocol)
+		return nil
+	}
+	if len(outbound.Settings.Servers) == 0 {
+		return fmt.Errorf("no servers")
+	}
+	for i, server := range outbound.Settings.Servers {
+		name := outbound.Tag
+		if len(outbound.Settings.Servers) > 1 {
+			name = fmt.Sprintf("%v %d", outbound.Tag, i+1)
+		}
+		var configURL string
+		var err error
+		if outbound.Protocol == "shadowsocks" {
+			configURL, err = makeShadowsocksURLfromJSON(&ServerInfo{
+				Remarks:    name,
+				Server:     server.Address,
+				ServerPort: server.Port,
+				Method:     server.Method,
+				Password:   server.Password,
+			})
+		} else {
+			var username, password string
+			if len(server.Users) > 0 {
+				username, password = server.Users[0].User, server.Users[0].Pass
+			}
+			configURL, err = makeSocks5URL(server.Address, server.Port, username, password, name)
+		}
+		if err == nil {
+			err = validateTransport(configURL)
+		}
+		if err != nil {
+			r.reject(fmt.Sprintf("%v server %d", item, i+1), err)
+			continue
+		}
+		r.Accepted = append(r.Accepted, configURL)
+	}
+	return nil
+}
+
+// makeSocks5URL encodes a SOCKS5 server as a socks5:// URL, with the name in the fragment.
+func makeSocks5URL(server string, port int, username, password, name string) (string, error) {
+	if server == "" || !isValidServer(server) {
+		return "", fmt.Errorf("invalid server")
+	}
+	if port <= 0 || port > 65535 {
+		return "", fmt.Errorf("invalid server port: %d", port)
+	}
+	u := url.URL{Scheme: "socks5", Host: net.JoinHostPort(server, strconv.Itoa(port))}
+	if username != "" {
+		u.User = url.UserPassword(username, password)
+	}
+	configURL := u.String()
+	if name != "" {
+		configURL += "#" + url.PathEscape(name)
+	}
+	return configURL, nil
+}
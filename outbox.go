@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// maxOutboxSize caps the outbox; the oldest reports are dropped first.
+	maxOutboxSize = 500
+	// Retry backoff bounds for flushing the outbox in the background.
+	outboxInitialDelay = 30 * time.Second
+	outboxMaxDelay     = 30 * time.Minute
+)
+
+// outboxMutex guards AppSettings.Outbox.
+var outboxMutex sync.Mutex
+
+// reportKey identifies a report for deduplication.
+func reportKey(r *connectivityReport) string {
+	return fmt.Sprintf("%v|%v|%v|%v|%v", r.Transport, r.Proto, r.Resolver, r.Time.Unix(), r.DurationMs)
+}
+
+// enqueueReport adds an uncollected report to the outbox, unless it is already queued.
+func enqueueReport(setting *AppSettings, r *connectivityReport) {
+	outboxMutex.Lock()
+	defer outboxMutex.Unlock()
+	key := reportKey(r)
+	for _, queued := range setting.Outbox {
+		if reportKey(queued) == key {
+			return
+		}
+	}
+	setting.Outbox = append(setting.Outbox, r)
+	if len(setting.Outbox) > maxOutboxSize {
+		log.Printf("Outbox full, dropping %d oldest reports", len(setting.Outbox)-maxOutboxSize)
+		setting.Outbox = setting.Outbox[len(setting.Outbox)-maxOutboxSize:]
+	}
+}
+
+// outboxSize returns the number of queued reports.
+func outboxSize(setting *AppSettings) int {
+	outboxMutex.Lock()
+	defer outboxMutex.Unlock()
+	return len(setting.Outbox)
+}
+
+// discardOutbox drops all queued reports.
+func discardOutbox(setting *AppSettings) {
+	outboxMutex.Lock()
+	defer outboxMutex.Unlock()
+	setting.Outbox = nil
+}
+
+// flushOutbox tries to collect every queued report at the destinations that have
+// not collected it yet, and keeps the ones that fail.
+// It returns the number of reports sent and the last collection error.
+func flushOutbox(setting *AppSettings) (int, error) {
+	settings := setting.reportSettings()
+	if !settings.Privacy.Consent {
+		return 0, errReportingDisabled
+	}
+	outboxMutex.Lock()
+	pending := setting.Outbox
+	setting.Outbox = nil
+	outboxMutex.Unlock()
+
+	var sent int
+	var lastErr error
+	for _, r := range pending {
+		if err := collectReport(r, settings); err != nil {
+			lastErr = err
+			enqueueReport(setting, r)
+			continue
+		}
+		sent++
+	}
+	log.Printf("Outbox flushed: %d sent, %d pending", sent, outboxSize(setting))
+	return sent, lastErr
+}
+
+// runOutboxRetry periodically flushes the outbox, backing off while the collector is unreachable.
+func runOutboxRetry(ctx *AppContext) {
+	delay := outboxInitialDelay
+	for {
+		time.Sleep(delay)
+		if outboxSize(ctx.Settings) == 0 {
+			delay = outboxInitialDelay
+			continue
+		}
+		sent, err := flushOutbox(ctx.Settings)
+		if sent > 0 || err == nil {
+			updateSettings(ctx)
+		}
+		if err != nil {
+			debugLog.Printf("Outbox flush failed: %v", err)
+			delay = min(2*delay, outboxMaxDelay)
+		} else {
+			delay = outboxInitialDelay
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboxDeduplicatesAndFlushes(t *testing.T) {
+	status := http.StatusBadRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	privacy := defaultReportPrivacy
+	privacy.Consent = true
+	setting := &AppSettings{ReporterURL: server.URL, ReportPrivacy: privacy}
+	r := &connectivityReport{Proto: "tcp", Transport: "ss://host:443", Time: time.Unix(1700000000, 0)}
+	enqueueReport(setting, r)
+	enqueueReport(setting, &connectivityReport{Proto: "tcp", Transport: "ss://host:443", Time: time.Unix(1700000000, 0)})
+	assert.Equal(t, 1, outboxSize(setting))
+
+	sent, err := flushOutbox(setting)
+	assert.Error(t, err)
+	assert.Equal(t, 0, sent)
+	assert.Equal(t, 1, outboxSize(setting))
+
+	status = http.StatusOK
+	sent, err = flushOutbox(setting)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, 0, outboxSize(setting))
+	assert.True(t, r.IsCollected())
+}
+
+func TestOutboxSizeCap(t *testing.T) {
+	setting := &AppSettings{}
+	for i := 0; i < maxOutboxSize+10; i++ {
+		enqueueReport(setting, &connectivityReport{Proto: "udp", Time: time.Unix(int64(i), 0)})
+	}
+	assert.Equal(t, maxOutboxSize, outboxSize(setting))
+	assert.Equal(t, int64(10), setting.Outbox[0].Time.Unix())
+}
+
+func TestMarshalSettingsWhileEnqueueing(t *testing.T) {
+	setting := &AppSettings{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			enqueueReport(setting, &connectivityReport{Proto: "tcp", Time: time.Unix(int64(i), 0)})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		_, err := marshalSettings(setting)
+		assert.NoError(t, err)
+	}
+	<-done
+	data, err := marshalSettings(setting)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"outbox"`)
+}
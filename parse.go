@@ -4,28 +4,42 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+	"github.com/Jigsaw-Code/outline-sdk/x/config"
 )
 
-func parseInputText(clipboardContent string) ([]Config, error) {
-	u, err := url.Parse(strings.TrimSpace(clipboardContent))
+func parseInputText(clipboardContent string, f *fetcher) ([]Config, error) {
+	input, name := splitTransportName(strings.TrimSpace(clipboardContent))
+	if strings.Contains(input, "|") {
+		// composite transport such as "split:3|ss://..."
+		chain, err := parseTransportChain(input)
+		if err != nil {
+			return []Config{}, err
+		}
+		return []Config{{Name: name, Transport: chain, TestReports: []*connectivityReport{}}}, nil
+	}
+	u, err := url.Parse(input)
 	// if parse is successful, check the schema
 	if err == nil {
 		switch u.Scheme {
 		case "ss", "socks5", "tls", "split":
 			// try to parse ss url
-			return []Config{{Transport: u.String()}}, nil
-		case "https":
-			// fetch list from remote config
-			var c []Config
-			configStrings, err := getDynamicConfig(u.String())
+			return []Config{{Name: name, Transport: u.String()}}, nil
+		case "ssconf":
+			// fetch the dynamic access key and keep its URL to re-fetch it later
+			transport, err := fetchDynamicKey(u.String(), f)
 			if err != nil {
 				return []Config{}, err
 			}
-			for _, configString := range configStrings {
-				c = append(c, Config{Transport: configString, Health: 0, TestReports: []*connectivityReport{}})
+			transport, keyName := splitTransportName(transport)
+			if name == "" {
+				name = keyName
 			}
-			fmt.Printf("Parsed %d configs from remote url\n", len(c))
-			return c, nil
+			return []Config{{Name: name, Transport: transport, KeyURL: u.String(), TestReports: []*connectivityReport{}}}, nil
+		case "https":
+			// config lists are added with AddSubscription, which keeps them updated
+			return []Config{}, fmt.Errorf("add https config lists as a subscription")
 		case "http":
 			// reject url due to security issue
 			return []Config{}, fmt.Errorf("not implemented yet")
@@ -36,3 +50,39 @@ func parseInputText(clipboardContent string) ([]Config, error) {
 	}
 	return []Config{}, fmt.Errorf("failed to parse input")
 }
+
+// splitTransportName removes the #fragment from the final hop of a transport
+// and returns it unescaped as the config name.
+func splitTransportName(transportConfig string) (string, string) {
+	parts := strings.Split(transportConfig, "|")
+	last, fragment, found := strings.Cut(parts[len(parts)-1], "#")
+	if !found {
+		return transportConfig, ""
+	}
+	parts[len(parts)-1] = last
+	name, err := url.PathUnescape(fragment)
+	if err != nil {
+		name = fragment
+	}
+	return strings.Join(parts, "|"), strings.TrimSpace(name)
+}
+
+// parseTransportChain validates every element of a pipe-chained transport
+// such as "tlsfrag:1|ss://..." and returns the chain with blanks trimmed.
+func parseTransportChain(chain string) (string, error) {
+	parts := strings.Split(strings.TrimSpace(chain), "|")
+	configparser := config.NewDefaultConfigParser()
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return "", fmt.Errorf("element %d of the transport chain is empty", i+1)
+		}
+		// Errors may contain secrets, so only the scheme of the element is reported
+		scheme, _, _ := strings.Cut(part, ":")
+		if _, err := configparser.WrapStreamDialer(&transport.TCPDialer{}, part); err != nil {
+			return "", fmt.Errorf("element %d (%v) of the transport chain is invalid", i+1, scheme)
+		}
+		parts[i] = part
+	}
+	return strings.Join(parts, "|"), nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSSKey = "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpzZWNyZXQ@example.com:443"
+
+func TestParseInputTextChains(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		want     string
+		wantName string
+	}{
+		{"split", "split:3|" + testSSKey, "split:3|" + testSSKey, "example.com:443"},
+		{"tlsfrag", "tlsfrag:1|" + testSSKey, "tlsfrag:1|" + testSSKey, "example.com:443"},
+		{"override socks5", "override:host=1.2.3.4|socks5://proxy.example.com:1080", "override:host=1.2.3.4|socks5://proxy.example.com:1080", "proxy.example.com:1080"},
+		{"spaces", " split:2 | tls:sni=example.org | " + testSSKey + "\n", "split:2|tls:sni=example.org|" + testSSKey, "example.com:443"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configs, err := parseInputText(tt.input, directFetcher())
+			require.NoError(t, err)
+			require.Len(t, configs, 1)
+			assert.Equal(t, tt.want, configs[0].Transport)
+			assert.Equal(t, tt.wantName, configs[0].DisplayName())
+		})
+	}
+}
+
+func TestParseInputTextInvalidChains(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{"bad split", "split:x|" + testSSKey, "element 1 (split)"},
+		{"unknown scheme", "foo:1|" + testSSKey, "element 1 (foo)"},
+		{"empty element", "split:3||" + testSSKey, "element 2 of the transport chain is empty"},
+		{"bad ss key", "split:3|ss://notbase64@example.com:443", "element 2 (ss)"},
+		{"trailing pipe", testSSKey + "|", "element 2 of the transport chain is empty"},
+		{"config list", "https://example.com/configs.txt", "subscription"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseInputText(tt.input, directFetcher())
+			assert.ErrorContains(t, err, tt.wantErr)
+			assert.NotContains(t, err.Error(), "Y2hhY2hh")
+		})
+	}
+}
+
+func TestParseInputTextNames(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		transport string
+		wantName  string
+	}{
+		{"fragment", testSSKey + "#Tokyo", testSSKey, "Tokyo"},
+		{"escaped fragment", testSSKey + "#My%20Server%20%F0%9F%9A%80", testSSKey, "My Server 🚀"},
+		{"chain fragment", "split:3|" + testSSKey + "#Split", "split:3|" + testSSKey, "Split"},
+		{"empty fragment", testSSKey + "#", testSSKey, "example.com:443"},
+		{"no fragment", testSSKey, testSSKey, "example.com:443"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configs, err := parseInputText(tt.input, directFetcher())
+			require.NoError(t, err)
+			require.Len(t, configs, 1)
+			assert.Equal(t, tt.transport, configs[0].Transport)
+			assert.Equal(t, tt.wantName, configs[0].DisplayName())
+		})
+	}
+}
+
+func TestSIP008Names(t *testing.T) {
+	data := []byte(`{"version": 1, "servers": [
+		{"id": "27b8a625-4f4b-4428-9f0f-8a2317db7c79", "remarks": "Home Server", "server": "example.com", "server_port": 8388, "password": "secret", "method": "chacha20-ietf-poly1305"},
+		{"id": "7842c068-c667-41f2-8f7d-04feece3cb67", "server": "example.org", "server_port": 8388, "password": "secret", "method": "chacha20-ietf-poly1305"}
+	]}`)
+	result, err := parseDynamicConfig(data)
+	require.NoError(t, err)
+	assert.Equal(t, formatSIP008, result.Format)
+	servers := result.Accepted
+	require.Len(t, servers, 2)
+
+	transport, name := splitTransportName(servers[0])
+	assert.Equal(t, "Home Server", name)
+	assert.NotContains(t, transport, "#")
+	_, name = splitTransportName(servers[1])
+	assert.Equal(t, "7842c068-c667-41f2-8f7d-04feece3cb67", name)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+// errNoQRCode is returned when no QR code can be located in an image.
+var errNoQRCode = errors.New("no QR code found in image")
+
+// decodeQRCode finds and decodes the QR code of a screenshot or a photo of
+// one, in any orientation and mirrored. Transparent pixels count as white, as
+// QR codes with a transparent background are shown on white.
+func decodeQRCode(img image.Image) (string, error) {
+	bitmap, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return "", fmt.Errorf("invalid image: %w", err)
+	}
+	hints := map[gozxing.DecodeHintType]interface{}{gozxing.DecodeHintType_TRY_HARDER: true}
+	result, err := qrcode.NewQRCodeReader().Decode(bitmap, hints)
+	if err != nil {
+		if _, ok := err.(gozxing.NotFoundException); ok {
+			return "", errNoQRCode
+		}
+		return "", fmt.Errorf("unreadable QR code: %w", err)
+	}
+	return result.GetText(), nil
+}
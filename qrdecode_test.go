@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/skip2/go-qrcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var qrLevels = []qrcode.RecoveryLevel{qrcode.Low, qrcode.Medium, qrcode.High, qrcode.Highest}
+
+func TestDecodeQRCodeModes(t *testing.T) {
+	for _, content := range []string{
+		testSSKey + "#Tōkyō ~ 1",
+		"12345678901234567890",
+		"HTTPS://EXAMPLE.COM/A $%*+-./:",
+		strings.Repeat("tls|split:3|"+testSSKey+"#name ", 6),
+	} {
+		for _, level := range qrLevels {
+			q, err := qrcode.New(content, level)
+			require.NoError(t, err)
+			text, err := decodeQRCode(q.Image(-3))
+			require.NoError(t, err, "level %d: %v", level, content)
+			assert.Equal(t, content, text)
+		}
+	}
+}
+
+func TestDecodeQRCodeVersions(t *testing.T) {
+	for version := 1; version <= 40; version++ {
+		for _, level := range qrLevels {
+			content := fmt.Sprintf("%d/%d", version, level)
+			q, err := qrcode.NewWithForcedVersion(content, version, level)
+			require.NoError(t, err)
+			text, err := decodeQRCode(q.Image(-2))
+			require.NoError(t, err, "version %d level %d", version, level)
+			assert.Equal(t, content, text)
+		}
+	}
+}
+
+// transformImage draws img into a gray image with the pixel at (x, y) moved
+// to transform(x, y, width, height).
+func transformImage(img image.Image, transform func(x, y, w, h int) (int, int)) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	x1, y1 := transform(w-1, h-1, w, h)
+	out := image.NewGray(image.Rect(0, 0, max(w, x1+1), max(h, y1+1)))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			tx, ty := transform(x, y, w, h)
+			out.Set(tx, ty, color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)))
+		}
+	}
+	return out
+}
+
+func TestDecodeQRCodeOrientation(t *testing.T) {
+	q, err := qrcode.New(testSSKey+"#Rotated", qrcode.Medium)
+	require.NoError(t, err)
+	img := q.Image(-4)
+	for name, transform := range map[string]func(x, y, w, h int) (int, int){
+		"rotated 90":  func(x, y, w, h int) (int, int) { return h - 1 - y, x },
+		"rotated 180": func(x, y, w, h int) (int, int) { return w - 1 - x, h - 1 - y },
+		"rotated 270": func(x, y, w, h int) (int, int) { return y, w - 1 - x },
+		"mirrored":    func(x, y, w, h int) (int, int) { return w - 1 - x, y },
+	} {
+		text, err := decodeQRCode(transformImage(img, transform))
+		require.NoError(t, err, name)
+		assert.Equal(t, testSSKey+"#Rotated", text, name)
+	}
+}
+
+func TestDecodeQRCodeCorrectsDamage(t *testing.T) {
+	content := testSSKey + "#Damaged"
+	q, err := qrcode.New(content, qrcode.Highest)
+	require.NoError(t, err)
+	img := q.Image(-4).(*image.Paletted)
+	// Paint over a 5x5 module area in the middle of the symbol, past the quiet zone
+	center := img.Bounds().Dx() / 2
+	for y := center - 10; y < center+10; y++ {
+		for x := center - 10; x < center+10; x++ {
+			img.Set(x, y, color.White)
+		}
+	}
+	text, err := decodeQRCode(img)
+	require.NoError(t, err)
+	assert.Equal(t, content, text)
+}
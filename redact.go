@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// redactedHost replaces host names in redacted reports.
+const redactedHost = "REDACTED"
+
+// errReportingDisabled is returned when reports are submitted without the user's consent.
+var errReportingDisabled = errors.New("report submission is not enabled")
+
+// ReportPrivacy holds the user's consent and redaction choices for submitted reports.
+type ReportPrivacy struct {
+	Consent           bool    `json:"consent"`
+	SuccessFraction   float64 `json:"successFraction"`
+	FailureFraction   float64 `json:"failureFraction"`
+	StripResolver     bool    `json:"stripResolver"`
+	StripHostnames    bool    `json:"stripHostnames"`
+	CoarsenTimestamps bool    `json:"coarsenTimestamps"`
+}
+
+// defaultReportPrivacy sends nothing until the user opts in.
+var defaultReportPrivacy = ReportPrivacy{SuccessFraction: 1, FailureFraction: 1}
+
+// redactReport returns a copy of the report with the redaction options applied.
+func redactReport(r *connectivityReport, privacy ReportPrivacy) *connectivityReport {
+	redacted := *r
+	// Delivery results are local bookkeeping, not part of the report.
+	redacted.Deliveries = nil
+	if redacted.Error != nil && (privacy.StripResolver || privacy.StripHostnames) {
+		redactedError := *redacted.Error
+		redacted.Error = &redactedError
+	}
+	// keptResolver is the resolver address that host name redaction leaves in messages
+	keptResolver := r.Resolver
+	if privacy.StripResolver {
+		redacted.Resolver = ""
+		keptResolver = ""
+		if redacted.Error != nil {
+			redacted.Error.Msg = redactResolverInText(redacted.Error.Msg, r.Resolver)
+		}
+	}
+	if privacy.StripHostnames {
+		hosts := append(transportHosts(redacted.Transport), redacted.TestDomain)
+		redacted.Transport = redactHostnames(redacted.Transport)
+		redacted.TestDomain = redactedHost
+		// The hash identifies the server as well as its host name does
+		redacted.ConfigHash = ""
+		if redacted.Error != nil {
+			redacted.Error.Msg = redactHostsInText(redacted.Error.Msg, hosts, keptResolver)
+		}
+	}
+	if privacy.CoarsenTimestamps {
+		redacted.Time = redacted.Time.Truncate(time.Hour)
+	}
+	return &redacted
+}
+
+// hostParams are the parameters of hops that hold host names, such as
+// tls:sni=example.com and override:host=example.com.
+var hostParams = map[string]bool{"sni": true, "certname": true, "host": true, "address": true}
+
+// ipCandidate matches text that may be an IP address, with or without a port.
+var ipCandidate = regexp.MustCompile(`[0-9A-Fa-f:.\[\]]*[:.][0-9A-Fa-f:.\[\]]*`)
+
+// redactHostnames replaces the host of every part of a transport chain, and
+// the host names in its parameters, keeping the ports.
+func redactHostnames(transportConfig string) string {
+	parts := strings.Split(transportConfig, "|")
+	for i, part := range parts {
+		u, err := url.Parse(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if u.Host != "" {
+			u.Host = redactHostPort(u.Host)
+		}
+		u.Opaque = redactHostParams(u.Opaque)
+		u.RawQuery = redactHostParams(u.RawQuery)
+		parts[i] = u.String()
+	}
+	return strings.Join(parts, "|")
+}
+
+// redactHostPort replaces the host of "host" or "host:port".
+func redactHostPort(hostPort string) string {
+	if _, port, err := net.SplitHostPort(hostPort); err == nil && port != "" {
+		return net.JoinHostPort(redactedHost, port)
+	}
+	return redactedHost
+}
+
+// redactHostParams replaces the values of the host parameters in "key=value&..." text.
+func redactHostParams(params string) string {
+	if !strings.Contains(params, "=") {
+		return params
+	}
+	pairs := strings.Split(params, "&")
+	for i, pair := range pairs {
+		key, value, found := strings.Cut(pair, "=")
+		if found && value != "" && hostParams[strings.ToLower(key)] {
+			pairs[i] = key + "=" + redactHostPort(value)
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// transportHosts returns the host names and addresses of a transport chain.
+func transportHosts(transportConfig string) []string {
+	var hosts []string
+	for _, part := range strings.Split(transportConfig, "|") {
+		u, err := url.Parse(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if u.Hostname() != "" {
+			hosts = append(hosts, u.Hostname())
+		}
+		for _, params := range []string{u.Opaque, u.RawQuery} {
+			for _, pair := range strings.Split(params, "&") {
+				key, value, found := strings.Cut(pair, "=")
+				if !found || value == "" || !hostParams[strings.ToLower(key)] {
+					continue
+				}
+				if host, _, err := net.SplitHostPort(value); err == nil {
+					value = host
+				}
+				hosts = append(hosts, value)
+			}
+		}
+	}
+	return hosts
+}
+
+// redactHostsInText replaces the given host names and every IP address but
+// the resolver's in text, such as an error message.
+func redactHostsInText(text string, hosts []string, resolver string) string {
+	// Longer names first, so that a name is not left half-replaced by its suffix
+	sort.Slice(hosts, func(i, j int) bool { return len(hosts[i]) > len(hosts[j]) })
+	for _, host := range hosts {
+		if host != "" && host != redactedHost {
+			text = strings.ReplaceAll(text, host, redactedHost)
+		}
+	}
+	resolverHost := resolver
+	if host, _, err := net.SplitHostPort(resolver); err == nil {
+		resolverHost = host
+	}
+	return ipCandidate.ReplaceAllStringFunc(text, func(match string) string {
+		// A trailing colon or period ends the sentence rather than the address
+		candidate := strings.TrimRight(match, ":.")
+		suffix := match[len(candidate):]
+		host, port := strings.Trim(candidate, "[]"), ""
+		if h, p, err := net.SplitHostPort(candidate); err == nil {
+			host, port = h, p
+		}
+		if net.ParseIP(host) == nil || host == resolverHost {
+			return match
+		}
+		if port != "" {
+			return net.JoinHostPort(redactedHost, port) + suffix
+		}
+		return redactedHost + suffix
+	})
+}
+
+// redactResolverInText replaces the resolver, as "host:port" and as "host",
+// in text such as an error message.
+func redactResolverInText(text string, resolver string) string {
+	if resolver == "" {
+		return text
+	}
+	if host, _, err := net.SplitHostPort(resolver); err == nil {
+		text = strings.ReplaceAll(text, resolver, redactHostPort(resolver))
+		resolver = host
+	}
+	return strings.ReplaceAll(text, resolver, redactedHost)
+}
+
+// previewReports returns the exact JSON that would be sent for the configs'
+// current test reports, one report per line.
+func previewReports(configs []Config, privacy ReportPrivacy) string {
+	var lines []string
+	for _, c := range configs {
+		for _, r := range c.TestReports {
+			data, err := json.Marshal(redactReport(r, privacy))
+			if err != nil {
+				continue
+			}
+			lines = append(lines, string(data))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCollector is a local report collector that records the reports it receives.
+type testCollector struct {
+	mu      sync.Mutex
+	reports []connectivityReport
+	server  *httptest.Server
+}
+
+func newTestCollector(t *testing.T) *testCollector {
+	c := &testCollector{}
+	c.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report connectivityReport
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		c.mu.Lock()
+		c.reports = append(c.reports, report)
+		c.mu.Unlock()
+	}))
+	t.Cleanup(c.server.Close)
+	return c
+}
+
+func (c *testCollector) received() []connectivityReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]connectivityReport(nil), c.reports...)
+}
+
+func newReportingSettings(collectorURL string, privacy ReportPrivacy) *AppSettings {
+	return &AppSettings{
+		ReporterURL:   collectorURL,
+		ReportPrivacy: privacy,
+		Configs: []Config{{
+			Transport: "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpwYXNz@example.com:443",
+			TestReports: []*connectivityReport{
+				{Resolver: "8.8.8.8:53", Proto: "tcp", Transport: "ss://REDACTED@example.com:443", Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+				{Resolver: "8.8.8.8:53", Proto: "udp", Transport: "ss://REDACTED@example.com:443", Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Error: &errorJSON{Op: "receive"}},
+			},
+		}},
+	}
+}
+
+func TestSubmitReportsRequiresConsent(t *testing.T) {
+	collector := newTestCollector(t)
+	setting := newReportingSettings(collector.server.URL, defaultReportPrivacy)
+
+	submitReports(setting)
+
+	assert.Empty(t, collector.received())
+	assert.Equal(t, 0, outboxSize(setting))
+}
+
+func TestSubmitReportsRedacts(t *testing.T) {
+	collector := newTestCollector(t)
+	privacy := ReportPrivacy{Consent: true, SuccessFraction: 1, FailureFraction: 1, StripResolver: true, StripHostnames: true, CoarsenTimestamps: true}
+	setting := newReportingSettings(collector.server.URL, privacy)
+
+	submitReports(setting)
+
+	received := collector.received()
+	require.Len(t, received, 2)
+	for _, r := range received {
+		assert.Empty(t, r.Resolver)
+		assert.Equal(t, "ss://REDACTED@REDACTED:443", r.Transport)
+		assert.Equal(t, time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC), r.Time)
+	}
+	// The stored reports are not modified
+	assert.Equal(t, "8.8.8.8:53", setting.Configs[0].TestReports[0].Resolver)
+	assert.True(t, setting.Configs[0].TestReports[0].IsCollected())
+}
+
+func TestSubmitReportsSampling(t *testing.T) {
+	collector := newTestCollector(t)
+	privacy := ReportPrivacy{Consent: true, SuccessFraction: 0, FailureFraction: 1}
+	setting := newReportingSettings(collector.server.URL, privacy)
+
+	submitReports(setting)
+
+	received := collector.received()
+	require.Len(t, received, 1)
+	assert.Equal(t, "udp", received[0].Proto)
+}
+
+func TestPreviewReportsMatchesSubmittedJSON(t *testing.T) {
+	privacy := ReportPrivacy{StripResolver: true}
+	setting := newReportingSettings("", privacy)
+	expected, err := json.Marshal(redactReport(setting.Configs[0].TestReports[0], privacy))
+	require.NoError(t, err)
+	assert.Contains(t, previewReports(setting.Configs, privacy), string(expected))
+}
+
+func TestRedactHostnames(t *testing.T) {
+	for transport, expected := range map[string]string{
+		"ss://REDACTED@example.com:443":                                  "ss://REDACTED@REDACTED:443",
+		"split:3|tls:sni=front.example.com|ss://x@1.2.3.4:80":            "split:3|tls:sni=REDACTED|ss://x@REDACTED:80",
+		"tls:sni=a.example.com&certname=b.example.com":                   "tls:sni=REDACTED&certname=REDACTED",
+		"override:host=example.com&port=443|socks5://[2001:db8::1]:1080": "override:host=REDACTED&port=443|socks5://REDACTED:1080",
+		"override:address=example.com:8443":                              "override:address=REDACTED:8443",
+	} {
+		assert.Equal(t, expected, redactHostnames(transport), transport)
+	}
+}
+
+func TestRedactReportResolver(t *testing.T) {
+	r := &connectivityReport{
+		Resolver:  "8.8.8.8:53",
+		Transport: "ss://REDACTED@server.example.com:443",
+		Error:     &errorJSON{Op: "resolve", Msg: "read udp 192.0.2.1:5353->8.8.8.8:53: i/o timeout, retried 8.8.8.8"},
+	}
+	redacted := redactReport(r, ReportPrivacy{StripResolver: true})
+	assert.Empty(t, redacted.Resolver)
+	assert.Equal(t, "read udp 192.0.2.1:5353->REDACTED:53: i/o timeout, retried REDACTED", redacted.Error.Msg)
+	// Host names are only redacted with StripHostnames
+	assert.Equal(t, r.Transport, redacted.Transport)
+	assert.Equal(t, "8.8.8.8:53", r.Resolver)
+	assert.Contains(t, r.Error.Msg, "8.8.8.8:53")
+
+	redacted = redactReport(r, ReportPrivacy{StripResolver: true, StripHostnames: true})
+	assert.Equal(t, "read udp REDACTED:5353->REDACTED:53: i/o timeout, retried REDACTED", redacted.Error.Msg)
+}
+
+func TestRedactReportHostnames(t *testing.T) {
+	r := &connectivityReport{
+		Resolver:   "8.8.8.8:53",
+		Transport:  "tls:sni=front.example.com|ss://REDACTED@server.example.com:443",
+		TestDomain: "blocked.example.org",
+		ConfigHash: "0123456789abcdef",
+		Error:      &errorJSON{Op: "connect", Msg: "dial tcp 203.0.113.7:443 via server.example.com for blocked.example.org: read udp 8.8.8.8:53 [2001:db8::1]:443: refused"},
+	}
+	redacted := redactReport(r, ReportPrivacy{StripHostnames: true})
+	assert.Equal(t, "tls:sni=REDACTED|ss://REDACTED@REDACTED:443", redacted.Transport)
+	assert.Equal(t, "REDACTED", redacted.TestDomain)
+	assert.Empty(t, redacted.ConfigHash)
+	assert.Equal(t, "dial tcp REDACTED:443 via REDACTED for REDACTED: read udp 8.8.8.8:53 REDACTED:443: refused", redacted.Error.Msg)
+	assert.Equal(t, "connect", redacted.Error.Op)
+
+	// The stored report is not modified
+	assert.Equal(t, "blocked.example.org", r.TestDomain)
+	assert.Contains(t, r.Error.Msg, "server.example.com")
+}
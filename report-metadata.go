@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/Jigsaw-Code/outline-sdk/x/config"
+)
+
+// reportSchemaVersion is incremented whenever the fields of connectivityReport change.
+// Version 1 is the original resolver/proto/transport/time/duration/error schema,
+// version 2 adds the app, platform and run metadata, and version 3 drops "collected".
+const reportSchemaVersion = 3
+
+// testTypeResolver is the test type of connectivity tests that query a DNS resolver through the transport.
+const testTypeResolver = "resolver"
+
+// appVersion is the app version from the FyneApp metadata, set at startup.
+var appVersion string
+
+// buildVersion is the fallback app version for builds whose FyneApp metadata has none,
+// set with -ldflags "-X main.buildVersion=<version>".
+var buildVersion string
+
+// formatAppVersion returns the app version reported for the FyneApp metadata version and build.
+// Without a metadata version it falls back to buildVersion, the module version, or "dev".
+func formatAppVersion(version string, build int) string {
+	if version != "" {
+		if build > 0 {
+			return fmt.Sprintf("%v (%d)", version, build)
+		}
+		return version
+	}
+	if buildVersion != "" {
+		return buildVersion
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
+// newConnectivityReport returns a report filled with the metadata shared by all tests of a run.
+func newConnectivityReport(runID, transportConfig, domain string) connectivityReport {
+	return connectivityReport{
+		SchemaVersion: reportSchemaVersion,
+		AppVersion:    appVersion,
+		OS:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+		TestType:      testTypeResolver,
+		TestDomain:    domain,
+		NetworkType:   networkTypeHint(),
+		RunID:         runID,
+		ConfigHash:    configHash(transportConfig),
+	}
+}
+
+// newRunID returns a random ID that ties together the reports of one test run.
+func newRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// configHash returns a short hash that identifies a config without revealing
+// it. Only the sanitized, normalized config is hashed, so that the hash cannot
+// be used to guess its password. It is "" for configs that do not parse.
+func configHash(transportConfig string) string {
+	sanitized, err := config.SanitizeConfig(normalizeTransport(transportConfig))
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(sanitized))
+	return hex.EncodeToString(sum[:8])
+}
+
+// networkTypeHint guesses the type of the network used for the default route
+// from the name of its interface: "wifi", "ethernet", "cellular",
+// "wifi_or_ethernet" or "unknown".
+func networkTypeHint() string {
+	// Dialing UDP sends no packets, it only picks the local address of the default route.
+	conn, err := net.Dial("udp", "8.8.8.8:53")
+	if err != nil {
+		return "unknown"
+	}
+	localIP := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return "unknown"
+	}
+	for _, iface := range interfaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(localIP) {
+				return interfaceType(iface.Name)
+			}
+		}
+	}
+	return "unknown"
+}
+
+// interfaceType maps common interface name prefixes to a network type.
+func interfaceType(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasPrefix(name, "wl"), strings.HasPrefix(name, "wi-fi"), strings.HasPrefix(name, "ath"):
+		return "wifi"
+	case strings.HasPrefix(name, "eth"), strings.HasPrefix(name, "enp"), strings.HasPrefix(name, "eno"), strings.HasPrefix(name, "ethernet"):
+		return "ethernet"
+	case strings.HasPrefix(name, "ww"), strings.HasPrefix(name, "rmnet"), strings.HasPrefix(name, "pdp_ip"), strings.HasPrefix(name, "ccmni"):
+		return "cellular"
+	case strings.HasPrefix(name, "en"):
+		// macOS names both Wi-Fi and Ethernet "en<N>"
+		return "wifi_or_ethernet"
+	default:
+		return "unknown"
+	}
+}
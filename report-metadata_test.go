@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConnectivityReport(t *testing.T) {
+	r := newConnectivityReport("run", testSSKey, "example.com")
+	assert.Equal(t, 3, r.SchemaVersion)
+	assert.Equal(t, reportSchemaVersion, r.SchemaVersion)
+	assert.Equal(t, testTypeResolver, r.TestType)
+	assert.Equal(t, "example.com", r.TestDomain)
+	assert.Equal(t, "run", r.RunID)
+	assert.Equal(t, runtime.GOOS, r.OS)
+	assert.Equal(t, runtime.GOARCH, r.Arch)
+	assert.Len(t, r.ConfigHash, 16)
+	assert.NotEqual(t, newRunID(), newRunID())
+}
+
+func TestConfigHash(t *testing.T) {
+	// The password is not hashed, so the hash cannot confirm a guessed one
+	otherPassword := "ss://chacha20-ietf-poly1305:other@example.com:443"
+	assert.Equal(t, configHash(testSSKey), configHash(otherPassword))
+	// The same server written differently has the same hash
+	assert.Equal(t, configHash(testSSKey), configHash("ss://chacha20-ietf-poly1305:secret@EXAMPLE.com:443"))
+	assert.NotEqual(t, configHash(testSSKey), configHash("split:3|"+testSSKey))
+	assert.NotEqual(t, configHash(testSSKey), configHash("ss://chacha20-ietf-poly1305:secret@example.org:443"))
+}
+
+func TestReportJSONCompatibility(t *testing.T) {
+	// Version 1 reports have none of the metadata
+	v1 := `{"resolver": "8.8.8.8:53", "proto": "udp", "transport": "ss://REDACTED@example.com:443",
+		"time": "2024-01-02T03:04:05Z", "duration_ms": 42, "error": {"op": "receive", "msg": "timeout"}}`
+	var r connectivityReport
+	require.NoError(t, json.Unmarshal([]byte(v1), &r))
+	assert.Equal(t, 0, r.SchemaVersion)
+	assert.Equal(t, "udp", r.Proto)
+	assert.Equal(t, "receive", r.Error.Op)
+	assert.Empty(t, r.ConfigHash)
+
+	// Current reports keep the field names of version 1 and only add to them
+	data, err := json.Marshal(newConnectivityReport("run", testSSKey, "example.com"))
+	require.NoError(t, err)
+	var fields map[string]any
+	require.NoError(t, json.Unmarshal(data, &fields))
+	for _, name := range []string{"schema_version", "resolver", "proto", "transport", "time", "duration_ms", "error",
+		"test_type", "test_domain", "config_hash", "run_id", "os", "arch", "network_type"} {
+		assert.Contains(t, fields, name)
+	}
+	assert.NotContains(t, fields, "collected")
+}
+
+func TestNetworkTypeHint(t *testing.T) {
+	assert.Contains(t, []string{"wifi", "ethernet", "cellular", "wifi_or_ethernet", "unknown"}, networkTypeHint())
+	for name, expected := range map[string]string{
+		"wlan0":   "wifi",
+		"Wi-Fi":   "wifi",
+		"eth0":    "ethernet",
+		"enp3s0":  "ethernet",
+		"rmnet0":  "cellular",
+		"pdp_ip0": "cellular",
+		"en0":     "wifi_or_ethernet",
+		"lo":      "unknown",
+	} {
+		assert.Equal(t, expected, interfaceType(name), name)
+	}
+}
+
+func TestFormatAppVersion(t *testing.T) {
+	assert.Equal(t, "1.2.0 (7)", formatAppVersion("1.2.0", 7))
+	assert.Equal(t, "1.2.0", formatAppVersion("1.2.0", 0))
+
+	// Without a metadata version the build number alone is meaningless.
+	fallback := formatAppVersion("", 1)
+	assert.NotEmpty(t, fallback)
+	assert.NotContains(t, fallback, " ")
+
+	defer func(v string) { buildVersion = v }(buildVersion)
+	buildVersion = "1.3.0-rc1"
+	assert.Equal(t, "1.3.0-rc1", formatAppVersion("", 1))
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// maxHistory is the number of test runs kept per config.
+const maxHistory = 20
+
+// testRun is the summary of one test of a config, kept to compute uptime.
+type testRun struct {
+	Time      time.Time `json:"time"`
+	Health    int       `json:"health"`
+	LatencyMs int64     `json:"latency_ms,omitempty"`
+	// ThroughputKbps is the measured download throughput, or 0 if it was not measured.
+	ThroughputKbps int64 `json:"throughput_kbps,omitempty"`
+}
+
+// recordTestRun appends the outcome of the latest test, with the measured
+// throughput, to the config's history.
+func recordTestRun(c *Config, throughputKbps int64) {
+	run := testRun{Time: time.Now().UTC().Truncate(time.Second), Health: c.Health, ThroughputKbps: throughputKbps}
+	var latencies []int64
+	for _, r := range c.TestReports {
+		if r.IsSuccess() {
+			latencies = append(latencies, r.DurationMs)
+		}
+	}
+	run.LatencyMs = median(latencies)
+	c.History = append(c.History, run)
+	if len(c.History) > maxHistory {
+		c.History = c.History[len(c.History)-maxHistory:]
+	}
+}
+
+// configScore is a config's score out of 100 and the parts it is made of.
+type configScore struct {
+	Total            float64
+	HealthPoints     float64
+	LatencyPoints    float64
+	UptimePoints     float64
+	ThroughputPoints float64
+	MedianLatencyMs  int64
+	// MedianThroughputKbps is 0 if the throughput was never measured.
+	MedianThroughputKbps int64
+	Uptime               float64
+	Runs                 int
+}
+
+// Score weights.
+const (
+	healthWeight     = 30
+	latencyWeight    = 25
+	uptimeWeight     = 25
+	throughputWeight = 20
+	// Latencies at or above slowLatencyMs get no latency points.
+	slowLatencyMs = 2000
+	// Throughputs at or above fastThroughputKbps get all throughput points.
+	fastThroughputKbps = 10000
+)
+
+// scoreConfig computes the score of a config from its current health, and
+// median latency, uptime and median throughput over its test history.
+func scoreConfig(c *Config) configScore {
+	var s configScore
+	switch c.Health {
+	case 1:
+		s.HealthPoints = healthWeight
+	case 2:
+		s.HealthPoints = healthWeight / 2
+	}
+
+	var latencies, throughputs []int64
+	var up int
+	for _, run := range c.History {
+		if run.Health == 1 {
+			up++
+		}
+		if run.LatencyMs > 0 {
+			latencies = append(latencies, run.LatencyMs)
+		}
+		if run.ThroughputKbps > 0 {
+			throughputs = append(throughputs, run.ThroughputKbps)
+		}
+	}
+	s.Runs = len(c.History)
+	if s.Runs > 0 {
+		s.Uptime = float64(up) / float64(s.Runs)
+		s.UptimePoints = uptimeWeight * s.Uptime
+	}
+	s.MedianLatencyMs = median(latencies)
+	if s.MedianLatencyMs > 0 && s.MedianLatencyMs < slowLatencyMs {
+		s.LatencyPoints = latencyWeight * (1 - float64(s.MedianLatencyMs)/slowLatencyMs)
+	}
+	s.MedianThroughputKbps = median(throughputs)
+	s.ThroughputPoints = throughputWeight * min(float64(s.MedianThroughputKbps)/fastThroughputKbps, 1)
+	s.Total = s.HealthPoints + s.LatencyPoints + s.UptimePoints + s.ThroughputPoints
+	return s
+}
+
+// Explain describes how the score was computed.
+func (s configScore) Explain() string {
+	throughput := "not measured"
+	if s.MedianThroughputKbps > 0 {
+		throughput = fmt.Sprintf("median %d kbit/s", s.MedianThroughputKbps)
+	}
+	return fmt.Sprintf("Score %.0f/100\nHealth: %.0f/%d\nLatency: %.0f/%d (median %d ms)\nUptime: %.0f/%d (%.0f%% of %d runs)\nThroughput: %.0f/%d (%v)",
+		s.Total,
+		s.HealthPoints, healthWeight,
+		s.LatencyPoints, latencyWeight, s.MedianLatencyMs,
+		s.UptimePoints, uptimeWeight, s.Uptime*100, s.Runs,
+		s.ThroughputPoints, throughputWeight, throughput)
+}
+
+// rankConfigs returns the config indices ordered by descending score.
+func rankConfigs(configs []Config) []int {
+	scores := make([]float64, len(configs))
+	ranked := make([]int, len(configs))
+	for i := range configs {
+		scores[i] = scoreConfig(&configs[i]).Total
+		ranked[i] = i
+	}
+	sort.SliceStable(ranked, func(a, b int) bool {
+		return scores[ranked[a]] > scores[ranked[b]]
+	})
+	return ranked
+}
+
+// bestHealthyConfig returns the index of the top-scoring config that passed
+// its last test, or false if none did.
+func bestHealthyConfig(configs []Config) (int, bool) {
+	for _, i := range rankConfigs(configs) {
+		if configs[i].Health == 1 {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// sortConfigsByScore reorders the configs by descending score.
+func sortConfigsByScore(setting *AppSettings) {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	ranked := rankConfigs(setting.Configs)
+	sorted := make([]Config, len(ranked))
+	for pos, i := range ranked {
+		sorted[pos] = setting.Configs[i]
+	}
+	setting.Configs = sorted
+}
+
+// median returns the median of values, or 0 if there are none.
+func median(values []int64) int64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]int64(nil), values...)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a] < sorted[b] })
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRankConfigs(t *testing.T) {
+	configs := []Config{
+		{Transport: "ss://failing", Health: 3, History: []testRun{{Health: 3}, {Health: 3}}},
+		{Transport: "ss://slow", Health: 1, History: []testRun{{Health: 1, LatencyMs: 1500}, {Health: 3}}},
+		{Transport: "ss://fast", Health: 1, History: []testRun{{Health: 1, LatencyMs: 100}, {Health: 1, LatencyMs: 300}}},
+	}
+	assert.Equal(t, []int{2, 1, 0}, rankConfigs(configs))
+
+	s := scoreConfig(&configs[2])
+	assert.Equal(t, int64(200), s.MedianLatencyMs)
+	assert.Equal(t, 1.0, s.Uptime)
+
+	setting := &AppSettings{Configs: configs}
+	sortConfigsByScore(setting)
+	assert.Equal(t, "ss://fast", setting.Configs[0].Transport)
+}
+
+func TestScoreThroughput(t *testing.T) {
+	unmeasured := Config{Health: 1, History: []testRun{{Health: 1, LatencyMs: 100}}}
+	fast := Config{Health: 1, History: []testRun{{Health: 1, LatencyMs: 100, ThroughputKbps: 20000}}}
+	slow := Config{Health: 1, History: []testRun{{Health: 1, LatencyMs: 100, ThroughputKbps: 2000}, {Health: 1, LatencyMs: 100, ThroughputKbps: 3000}}}
+
+	assert.Equal(t, 0.0, scoreConfig(&unmeasured).ThroughputPoints)
+	assert.Contains(t, scoreConfig(&unmeasured).Explain(), "Throughput: 0/20 (not measured)")
+	assert.Equal(t, float64(throughputWeight), scoreConfig(&fast).ThroughputPoints)
+	s := scoreConfig(&slow)
+	assert.Equal(t, int64(2500), s.MedianThroughputKbps)
+	assert.Equal(t, 5.0, s.ThroughputPoints)
+	assert.Equal(t, []int{1, 2, 0}, rankConfigs([]Config{unmeasured, fast, slow}))
+
+	c := Config{Health: 1, TestReports: []*connectivityReport{{DurationMs: 80}}}
+	recordTestRun(&c, 4000)
+	assert.Equal(t, int64(4000), c.History[0].ThroughputKbps)
+}
+
+func TestBestHealthyConfig(t *testing.T) {
+	configs := []Config{
+		{Transport: "ss://untested"},
+		{Transport: "ss://failing-now", Health: 3, History: []testRun{{Health: 1, LatencyMs: 10}, {Health: 1, LatencyMs: 10}}},
+		{Transport: "ss://working", Health: 1, History: []testRun{{Health: 3}}},
+	}
+	// The failing config scores higher on its history, but is not picked
+	assert.Equal(t, 1, rankConfigs(configs)[0])
+	best, ok := bestHealthyConfig(configs)
+	assert.True(t, ok)
+	assert.Equal(t, 2, best)
+
+	_, ok = bestHealthyConfig(configs[:2])
+	assert.False(t, ok)
+}
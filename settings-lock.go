@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// settingsMutex guards AppSettings.Configs, including their test reports, and
+// AppSettings.Subscriptions. The UI, tests and background refreshes all change
+// them, so slow work such as tests and fetches runs on copies and applies its
+// results by config ID under the lock. Never call into the UI while holding it.
+var settingsMutex sync.RWMutex
+
+// selectedConfigID is the ID of the config selected in the list, or "" if none.
+var selectedConfigID string
+
+// resultConfigID is the ID of the config shown on the test result page.
+var resultConfigID string
+
+// newConfigID returns a random ID that identifies a config while its index
+// changes with sorting, deletion and refreshes.
+func newConfigID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// ensureConfigIDs gives an ID to every config that has none, such as new
+// configs and configs saved by older versions.
+func ensureConfigIDs(configs []Config) {
+	for i := range configs {
+		if configs[i].ID == "" {
+			configs[i].ID = newConfigID()
+		}
+	}
+}
+
+// configIndex returns the index of the config with the given ID, or -1.
+// The caller holds settingsMutex.
+func (s *AppSettings) configIndex(id string) int {
+	if id == "" {
+		return -1
+	}
+	for i := range s.Configs {
+		if s.Configs[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// configByID returns a copy of the config with the given ID.
+func (s *AppSettings) configByID(id string) (Config, bool) {
+	settingsMutex.RLock()
+	defer settingsMutex.RUnlock()
+	if i := s.configIndex(id); i >= 0 {
+		return s.Configs[i], true
+	}
+	return Config{}, false
+}
+
+// configAt returns a copy of the config at index i of the list.
+func (s *AppSettings) configAt(i int) (Config, bool) {
+	settingsMutex.RLock()
+	defer settingsMutex.RUnlock()
+	if i < 0 || i >= len(s.Configs) {
+		return Config{}, false
+	}
+	return s.Configs[i], true
+}
+
+// configsSnapshot returns a copy of the configs.
+func (s *AppSettings) configsSnapshot() []Config {
+	settingsMutex.RLock()
+	defer settingsMutex.RUnlock()
+	return append([]Config(nil), s.Configs...)
+}
+
+// updateConfig changes the config with the given ID under the lock. It
+// returns false if the config was removed.
+func (s *AppSettings) updateConfig(id string, update func(c *Config)) bool {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	i := s.configIndex(id)
+	if i < 0 {
+		return false
+	}
+	update(&s.Configs[i])
+	return true
+}
+
+// addConfigs appends configs with new IDs.
+func (s *AppSettings) addConfigs(configs ...Config) {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	for _, c := range configs {
+		c.ID = newConfigID()
+		s.Configs = append(s.Configs, c)
+	}
+}
+
+// removeConfig removes the config with the given ID.
+func (s *AppSettings) removeConfig(id string) {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	if i := s.configIndex(id); i >= 0 {
+		s.Configs = append(s.Configs[:i], s.Configs[i+1:]...)
+	}
+}
+
+// forgetRemovedSelection clears the selected and shown configs if a refresh
+// or removal dropped them, so that no action picks another config by mistake.
+func (s *AppSettings) forgetRemovedSelection() {
+	if _, ok := s.configByID(selectedConfigID); !ok {
+		selectedConfigID = ""
+	}
+	if _, ok := s.configByID(resultConfigID); !ok {
+		resultConfigID = ""
+	}
+}
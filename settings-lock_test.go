@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigIDs(t *testing.T) {
+	setting := &AppSettings{Configs: []Config{{Name: "Old"}, {Name: "Kept", ID: "kept"}}}
+	ensureConfigIDs(setting.Configs)
+	assert.NotEmpty(t, setting.Configs[0].ID)
+	assert.Equal(t, "kept", setting.Configs[1].ID)
+
+	// Added configs get new IDs, even copies of existing ones
+	setting.addConfigs(setting.Configs[1])
+	require.Len(t, setting.Configs, 3)
+	assert.NotEqual(t, "kept", setting.Configs[2].ID)
+
+	// The ID still finds the config after it moves
+	setting.removeConfig(setting.Configs[0].ID)
+	c, ok := setting.configByID("kept")
+	require.True(t, ok)
+	assert.Equal(t, "Kept", c.Name)
+	assert.True(t, setting.updateConfig("kept", func(c *Config) { c.Health = 1 }))
+	assert.Equal(t, 1, setting.Configs[0].Health)
+
+	setting.removeConfig("kept")
+	assert.False(t, setting.updateConfig("kept", func(c *Config) { c.Health = 2 }))
+	_, ok = setting.configByID("kept")
+	assert.False(t, ok)
+}
@@ -4,10 +4,14 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
@@ -30,6 +34,100 @@ func makeSettingsPageContent(ctx *AppContext, navChannel chan NavEvent) fyne.Can
 	reporterEntry := widget.NewEntry()
 	reporterEntry.Text = settings.ReporterURL
 
+	throughputLabel := widget.NewRichTextFromMarkdown("**Throughput test URL** (empty to skip)")
+	throughputEntry := widget.NewEntry()
+	throughputEntry.Text = settings.ThroughputURL
+
+	blockedLabel := widget.NewRichTextFromMarkdown("**Blocked domains** (one per line)")
+	blockedEntry := widget.NewMultiLineEntry()
+	blockedEntry.Text = strings.Join(settings.BlockedDomains, "\n")
+
+	checkViaTunnel := widget.NewCheck("Send reports through the active tunnel", nil)
+	checkViaTunnel.Checked = settings.ReportViaTunnel
+
+	outboxLabel := widget.NewLabel("")
+	refreshOutboxLabel := func() {
+		outboxLabel.SetText(fmt.Sprintf("Outbox: %d reports waiting", outboxSize(settings)))
+	}
+	refreshOutboxLabel()
+	var flushButton *widget.Button
+	flushButton = widget.NewButton("Flush now", func() {
+		flushButton.Disable()
+		go func() {
+			if _, err := flushOutbox(settings); err != nil {
+				log.Println("Error flushing outbox:", err)
+			}
+			updateSettings(ctx)
+			refreshOutboxLabel()
+			flushButton.Enable()
+		}()
+	})
+	discardButton := widget.NewButton("Discard", func() {
+		dialog.ShowConfirm("Discard reports", "Discard all reports waiting in the outbox?", func(confirm bool) {
+			if confirm {
+				discardOutbox(settings)
+				updateSettings(ctx)
+				refreshOutboxLabel()
+			}
+		}, ctx.Window)
+	})
+
+	privacy := settings.ReportPrivacy
+	successSlider := widget.NewSlider(0, 1)
+	successSlider.Step = 0.05
+	successSlider.Value = privacy.SuccessFraction
+	failureSlider := widget.NewSlider(0, 1)
+	failureSlider.Step = 0.05
+	failureSlider.Value = privacy.FailureFraction
+	checkStripResolver := widget.NewCheck("Strip resolver", nil)
+	checkStripResolver.Checked = privacy.StripResolver
+	checkStripHostnames := widget.NewCheck("Strip hostnames", nil)
+	checkStripHostnames.Checked = privacy.StripHostnames
+	checkCoarsenTimestamps := widget.NewCheck("Coarsen timestamps to the hour", nil)
+	checkCoarsenTimestamps.Checked = privacy.CoarsenTimestamps
+	currentPrivacy := func() ReportPrivacy {
+		return ReportPrivacy{
+			SuccessFraction:   successSlider.Value,
+			FailureFraction:   failureSlider.Value,
+			StripResolver:     checkStripResolver.Checked,
+			StripHostnames:    checkStripHostnames.Checked,
+			CoarsenTimestamps: checkCoarsenTimestamps.Checked,
+		}
+	}
+	showPreview := func(title, confirm string, callback func(bool)) {
+		preview := previewReports(settings.configsSnapshot(), currentPrivacy())
+		if preview == "" {
+			preview = "No test reports yet"
+		}
+		previewEntry := widget.NewMultiLineEntry()
+		previewEntry.SetText(preview)
+		previewEntry.Wrapping = fyne.TextWrapBreak
+		previewEntry.SetMinRowsVisible(10)
+		content := container.NewVBox(
+			widget.NewLabel("Reports are sent to "+settings.ReporterURL+" as:"),
+			previewEntry,
+		)
+		d := dialog.NewCustomConfirm(title, confirm, "Cancel", content, callback, ctx.Window)
+		d.Resize(fyne.NewSize(500, 400))
+		d.Show()
+	}
+	var checkConsent *widget.Check
+	checkConsent = widget.NewCheck("Share test reports", func(value bool) {
+		if !value || settings.ReportPrivacy.Consent {
+			return
+		}
+		// Opting in requires reviewing the data that will be sent
+		showPreview("Share test reports?", "Share", func(confirm bool) {
+			if !confirm {
+				checkConsent.SetChecked(false)
+			}
+		})
+	})
+	checkConsent.Checked = privacy.Consent
+	previewButton := widget.NewButton("Preview reports", func() {
+		showPreview("Report preview", "OK", func(bool) {})
+	})
+
 	checkUDP := widget.NewCheck("UDP", func(value bool) {
 		log.Println("Check set to", value)
 		settings.Udp = value
@@ -71,12 +169,19 @@ func makeSettingsPageContent(ctx *AppContext, navChannel chan NavEvent) fyne.Can
 	addressEntry.Text = settings.LocalAddress
 
 	saveButton := widget.NewButton("Save", func() {
+		settingsMutex.Lock()
 		ctx.Settings.Domain = domainEntry.Text
 		ctx.Settings.ResolverHost = dnsEntry.Text
 		ctx.Settings.ReporterURL = reporterEntry.Text
 		ctx.Settings.Udp = checkUDP.Checked
 		ctx.Settings.Tcp = checkTCP.Checked
 		ctx.Settings.LocalAddress = addressEntry.Text
+		ctx.Settings.BlockedDomains = splitLines(blockedEntry.Text)
+		ctx.Settings.ThroughputURL = strings.TrimSpace(throughputEntry.Text)
+		ctx.Settings.ReportViaTunnel = checkViaTunnel.Checked
+		ctx.Settings.ReportPrivacy = currentPrivacy()
+		ctx.Settings.ReportPrivacy.Consent = checkConsent.Checked
+		settingsMutex.Unlock()
 		updateSettings(ctx)
 	})
 	saveButton.Importance = widget.HighImportance
@@ -101,11 +206,33 @@ func makeSettingsPageContent(ctx *AppContext, navChannel chan NavEvent) fyne.Can
 			dnsLabel,
 			dnsEntry,
 			protocolSelect,
+			throughputLabel,
+			throughputEntry,
 			reporterLabel,
 			reporterEntry,
+			checkViaTunnel,
+			outboxLabel,
+			container.NewGridWithColumns(2, flushButton, discardButton),
+			blockedLabel,
+			blockedEntry,
+		))
+
+	reportingSettings := widget.NewAccordionItem("Reporting",
+		container.NewVBox(checkConsent,
+			widget.NewLabel("Fraction of successful reports sent"),
+			successSlider,
+			widget.NewLabel("Fraction of failed reports sent"),
+			failureSlider,
+			checkStripResolver,
+			checkStripHostnames,
+			checkCoarsenTimestamps,
+			previewButton,
+			makeCollectorsEditor(ctx),
 		))
 
-	accordion := widget.NewAccordion(advancedSettings)
+	subscriptionSettings := widget.NewAccordionItem("Subscriptions", container.NewVBox(makeSubscriptionsEditor(ctx), makeFetchSettings(ctx)))
+
+	accordion := widget.NewAccordion(advancedSettings, reportingSettings, subscriptionSettings)
 
 	return container.NewVBox(
 		header,
@@ -116,3 +243,282 @@ func makeSettingsPageContent(ctx *AppContext, navChannel chan NavEvent) fyne.Can
 		saveButton,
 	)
 }
+
+// splitLines returns the non-blank, trimmed lines of s.
+func splitLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// makeCollectorsEditor lists the report collector destinations and lets the user add and remove them.
+func makeCollectorsEditor(ctx *AppContext) fyne.CanvasObject {
+	rows := container.NewVBox()
+	var refresh func()
+	refresh = func() {
+		rows.RemoveAll()
+		collectors := ctx.Settings.collectorsSnapshot()
+		if len(collectors) == 0 {
+			rows.Add(widget.NewLabel("No collectors, reports go to the Reporter URL"))
+		}
+		for _, d := range collectors {
+			name := d.Name
+			label := widget.NewLabel(fmt.Sprintf("%v (%v): %v", d.Name, d.Kind, d.Target))
+			label.Wrapping = fyne.TextWrapBreak
+			deleteButton := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+				ctx.Settings.removeCollector(name)
+				updateSettings(ctx)
+				refresh()
+			})
+			rows.Add(container.NewBorder(nil, nil, nil, deleteButton, label))
+		}
+	}
+	refresh()
+	addButton := widget.NewButtonWithIcon("Add collector", theme.ContentAddIcon(), func() {
+		showAddCollector(ctx, refresh)
+	})
+	return container.NewVBox(widget.NewRichTextFromMarkdown("**Collectors**"), rows, addButton)
+}
+
+// refreshIntervals are the refresh intervals offered for subscriptions, in minutes.
+var refreshIntervals = map[string]int{"Manual": 0, "Hourly": 60, "Every 6 hours": 6 * 60, "Daily": 24 * 60}
+
+// makeSubscriptionsEditor lists the subscriptions and lets the user refresh them,
+// change their refresh interval and remove them together with their configs.
+func makeSubscriptionsEditor(ctx *AppContext) fyne.CanvasObject {
+	rows := container.NewVBox()
+	var refresh func()
+	refresh = func() {
+		rows.RemoveAll()
+		settingsMutex.RLock()
+		subscriptions := append([]Subscription(nil), ctx.Settings.Subscriptions...)
+		settingsMutex.RUnlock()
+		if len(subscriptions) == 0 {
+			rows.Add(widget.NewLabel("No subscriptions, add an https:// config list with +"))
+		}
+		for _, sub := range subscriptions {
+			sub := sub
+			status := fmt.Sprintf("%v: %d configs, fetched %v", sub.Name, ctx.Settings.subscriptionConfigCount(sub.URL), sub.LastFetched.Format(time.DateTime))
+			if sub.LastError != "" {
+				status += "\nLast refresh failed: " + sub.LastError
+			}
+			label := widget.NewLabel(status)
+			label.Wrapping = fyne.TextWrapBreak
+			intervalSelect := widget.NewSelect([]string{"Manual", "Hourly", "Every 6 hours", "Daily"}, func(value string) {
+				settingsMutex.Lock()
+				if i := ctx.Settings.findSubscription(sub.URL); i >= 0 {
+					ctx.Settings.Subscriptions[i].RefreshMinutes = refreshIntervals[value]
+				}
+				settingsMutex.Unlock()
+				updateSettings(ctx)
+			})
+			for name, minutes := range refreshIntervals {
+				if minutes == sub.RefreshMinutes {
+					intervalSelect.Selected = name
+				}
+			}
+			refreshButton := widget.NewButtonWithIcon("", theme.ViewRefreshIcon(), nil)
+			refreshButton.OnTapped = func() {
+				// Fetch off the UI goroutine, like the background refresh
+				refreshButton.Disable()
+				go func() {
+					diff, err := ctx.Settings.RefreshSubscription(sub.URL)
+					if err != nil {
+						dialog.ShowError(err, ctx.Window)
+					} else {
+						showImportSummary(ctx, "Subscription", diff.Summary())
+					}
+					ctx.Settings.forgetRemovedSelection()
+					updateSettings(ctx)
+					refresh()
+				}()
+			}
+			headersButton := widget.NewButtonWithIcon("", theme.DocumentCreateIcon(), func() {
+				showSubscriptionHeaders(ctx, sub)
+			})
+			deleteButton := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+				dialog.ShowConfirm("Remove subscription", "Remove the subscription and its configs?", func(confirm bool) {
+					if confirm {
+						if err := ctx.Settings.RemoveSubscription(sub.URL); err != nil {
+							dialog.ShowError(err, ctx.Window)
+						}
+						ctx.Settings.forgetRemovedSelection()
+						updateSettings(ctx)
+						refresh()
+					}
+				}, ctx.Window)
+			})
+			rows.Add(container.NewBorder(nil, nil, nil, container.NewHBox(intervalSelect, headersButton, refreshButton, deleteButton), label))
+		}
+	}
+	refresh()
+	return rows
+}
+
+// makeFetchSettings lets the user fetch config lists and dynamic access keys
+// through the tunnel or a healthy config.
+func makeFetchSettings(ctx *AppContext) fyne.CanvasObject {
+	options := []string{"Direct", "Through the tunnel"}
+	configIDs := make(map[string]string)
+	configs := ctx.Settings.configsSnapshot()
+	for i := range configs {
+		c := &configs[i]
+		if c.Health != 1 && c.ID != ctx.Settings.FetchConfigID {
+			continue
+		}
+		option := "Through " + c.DisplayName()
+		if _, exists := configIDs[option]; exists {
+			option += fmt.Sprintf(" (%d)", i+1)
+		}
+		configIDs[option] = c.ID
+		options = append(options, option)
+	}
+	routeSelect := widget.NewSelect(options, func(value string) {
+		switch value {
+		case "Direct":
+			ctx.Settings.FetchRoute, ctx.Settings.FetchConfigID = fetchDirect, ""
+		case "Through the tunnel":
+			ctx.Settings.FetchRoute, ctx.Settings.FetchConfigID = fetchViaTunnel, ""
+		default:
+			ctx.Settings.FetchRoute, ctx.Settings.FetchConfigID = fetchViaConfig, configIDs[value]
+		}
+		updateSettings(ctx)
+	})
+	routeSelect.Selected = "Direct"
+	switch ctx.Settings.FetchRoute {
+	case fetchViaTunnel:
+		routeSelect.Selected = "Through the tunnel"
+	case fetchViaConfig:
+		for option, id := range configIDs {
+			if id == ctx.Settings.FetchConfigID {
+				routeSelect.Selected = option
+			}
+		}
+	}
+	return container.NewVBox(
+		widget.NewRichTextFromMarkdown("**Fetch config lists and keys**"),
+		routeSelect,
+	)
+}
+
+// newHeadersEntry returns an entry for extra request headers, one
+// "Name: value" per line, filled with headers.
+func newHeadersEntry(headers map[string]string) *widget.Entry {
+	var lines []string
+	for name, value := range headers {
+		lines = append(lines, name+": "+value)
+	}
+	sort.Strings(lines)
+	headersEntry := widget.NewMultiLineEntry()
+	headersEntry.SetPlaceHolder("Authorization: Bearer token")
+	headersEntry.SetText(strings.Join(lines, "\n"))
+	headersEntry.Validator = func(text string) error {
+		_, err := parseHeaderLines(splitLines(text))
+		return err
+	}
+	return headersEntry
+}
+
+// showSubscriptionHeaders lets the user edit the extra request headers of the subscription.
+func showSubscriptionHeaders(ctx *AppContext, sub Subscription) {
+	headersEntry := newHeadersEntry(sub.Headers)
+	headersItem := widget.NewFormItem("Headers", headersEntry)
+	headersItem.HintText = "One \"Name: value\" per line, sent only to " + sub.Name
+	form := []*widget.FormItem{headersItem}
+	dialog.ShowForm("Request headers", "Save", "Cancel", form, func(confirm bool) {
+		if !confirm {
+			return
+		}
+		headers, _ := parseHeaderLines(splitLines(headersEntry.Text))
+		ctx.Settings.setSubscriptionHeaders(sub.URL, headers)
+		updateSettings(ctx)
+	}, ctx.Window)
+}
+
+// showAddCollector shows a form to add a report collector destination.
+func showAddCollector(ctx *AppContext, onAdded func()) {
+	kindSelect := widget.NewSelect([]string{collectorHTTPS, collectorFile, collectorUnix, collectorStdout}, nil)
+	kindSelect.SetSelected(collectorHTTPS)
+	nameEntry := widget.NewEntry()
+	nameEntry.Validator = func(s string) error {
+		if strings.TrimSpace(s) == "" {
+			return fmt.Errorf("name is required")
+		}
+		for _, d := range ctx.Settings.collectorsSnapshot() {
+			if d.Name == s {
+				return fmt.Errorf("name is already used")
+			}
+		}
+		return nil
+	}
+	targetEntry := widget.NewEntry()
+	targetEntry.SetPlaceHolder("URL, file path or socket path")
+	retryEntry := widget.NewEntry()
+	retryEntry.SetText("3")
+	retryEntry.Validator = validateInt
+	delayEntry := widget.NewEntry()
+	delayEntry.SetText("1000")
+	delayEntry.Validator = validateInt
+	successEntry := widget.NewEntry()
+	successEntry.SetText("1")
+	successEntry.Validator = validateFraction
+	failureEntry := widget.NewEntry()
+	failureEntry.SetText("1")
+	failureEntry.Validator = validateFraction
+	maxSizeEntry := widget.NewEntry()
+	maxSizeEntry.SetText(strconv.Itoa(defaultMaxFileBytes))
+	maxSizeEntry.Validator = validateInt
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Kind", kindSelect),
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Target", targetEntry),
+		widget.NewFormItem("Max retries", retryEntry),
+		widget.NewFormItem("Retry delay (ms)", delayEntry),
+		widget.NewFormItem("Success fraction", successEntry),
+		widget.NewFormItem("Failure fraction", failureEntry),
+		widget.NewFormItem("Rotate file at (bytes)", maxSizeEntry),
+	}
+	dialog.ShowForm("Add collector", "Add", "Cancel", items, func(confirm bool) {
+		if !confirm {
+			return
+		}
+		d := CollectorDestination{
+			Name:   strings.TrimSpace(nameEntry.Text),
+			Kind:   kindSelect.Selected,
+			Target: strings.TrimSpace(targetEntry.Text),
+		}
+		d.MaxRetry, _ = strconv.Atoi(retryEntry.Text)
+		d.RetryDelayMs, _ = strconv.ParseInt(delayEntry.Text, 10, 64)
+		d.SuccessFraction, _ = strconv.ParseFloat(successEntry.Text, 64)
+		d.FailureFraction, _ = strconv.ParseFloat(failureEntry.Text, 64)
+		if d.Kind == collectorFile {
+			d.MaxFileBytes, _ = strconv.ParseInt(maxSizeEntry.Text, 10, 64)
+		}
+		settingsMutex.Lock()
+		ctx.Settings.Collectors = append(ctx.Settings.Collectors, d)
+		settingsMutex.Unlock()
+		updateSettings(ctx)
+		onAdded()
+	}, ctx.Window)
+}
+
+// validateInt accepts non-negative integers.
+func validateInt(s string) error {
+	if n, err := strconv.ParseInt(s, 10, 64); err != nil || n < 0 {
+		return fmt.Errorf("must be a non-negative number")
+	}
+	return nil
+}
+
+// validateFraction accepts numbers between 0 and 1.
+func validateFraction(s string) error {
+	if f, err := strconv.ParseFloat(s, 64); err != nil || f < 0 || f > 1 {
+		return fmt.Errorf("must be between 0 and 1")
+	}
+	return nil
+}
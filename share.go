@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// shareQRSize is the width and height of shared QR code images in pixels.
+const shareQRSize = 512
+
+// ShareLink returns the link that imports the config into another app: the
+// ssconf:// URL of dynamic access keys, so that key rotations still reach the
+// receiver, or else the transport. withName adds the config name as the
+// #fragment of the final hop, the SIP002 remark.
+func (c *Config) ShareLink(withName bool) string {
+	link := c.Transport
+	if c.KeyURL != "" {
+		link = c.KeyURL
+	}
+	if withName {
+		link = withRemark(link, c.Name)
+	}
+	return link
+}
+
+// withRemark adds a config name to a link as the #fragment of its final hop.
+func withRemark(link string, name string) string {
+	if name = strings.TrimSpace(name); name != "" {
+		link += "#" + url.PathEscape(name)
+	}
+	return link
+}
+
+// shareQRCode encodes a share link as a PNG QR code.
+func shareQRCode(link string) ([]byte, error) {
+	return qrcode.Encode(link, qrcode.Medium, shareQRSize)
+}
+
+// linkHasSecrets reports whether a share link carries credentials: the
+// password of an ss hop, the user info of other hops, or the URL of a dynamic
+// access key. Links that can't be parsed are assumed to have secrets.
+func linkHasSecrets(link string) bool {
+	for _, hop := range strings.Split(link, "|") {
+		u, err := url.Parse(strings.TrimSpace(hop))
+		if err != nil || u.Scheme == "ss" || u.Scheme == "ssconf" || u.User != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// unsafeFileNameChars matches characters left out of saved file names.
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// shareFileName returns the file name of the saved QR code of a config.
+func shareFileName(c *Config) string {
+	name := strings.Trim(unsafeFileNameChars.ReplaceAllString(c.DisplayName(), "-"), "-.")
+	if name == "" {
+		name = "config"
+	}
+	return name + ".png"
+}
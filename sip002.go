@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// makeShadowsocksURLfromJSON encodes a server as a SIP002 URL
+// (https://shadowsocks.org/doc/sip002.html): the method and password are
+// base64url encoded in the userinfo, the prefix and plugin go in the query,
+// and the remarks, or the ID if there are none, go in the fragment.
+func makeShadowsocksURLfromJSON(config *ServerInfo) (string, error) {
+	if config.ServerPort == 0 {
+		return "", fmt.Errorf("missing server port")
+	}
+	if config.ServerPort < 0 || config.ServerPort > 65535 {
+		return "", fmt.Errorf("invalid server port: %d", config.ServerPort)
+	}
+	if config.Method == "" {
+		return "", fmt.Errorf("missing method")
+	}
+	if strings.Contains(config.Method, ":") {
+		return "", fmt.Errorf("invalid method")
+	}
+	if config.Password == "" {
+		return "", fmt.Errorf("missing password")
+	}
+	if config.Server == "" {
+		return "", fmt.Errorf("missing server")
+	}
+	if !isValidServer(config.Server) {
+		return "", fmt.Errorf("invalid server")
+	}
+	if strings.Contains(config.Plugin, ";") {
+		return "", fmt.Errorf("invalid plugin")
+	}
+	userinfo := base64.RawURLEncoding.EncodeToString([]byte(config.Method + ":" + config.Password))
+	configURL := "ss://" + userinfo + "@" + net.JoinHostPort(config.Server, strconv.Itoa(config.ServerPort))
+	query := url.Values{}
+	if config.Prefix != "" {
+		query.Set("prefix", config.Prefix)
+	}
+	if config.Plugin != "" {
+		plugin := config.Plugin
+		if config.PluginOpts != "" {
+			plugin += ";" + config.PluginOpts
+		}
+		query.Set("plugin", plugin)
+	}
+	if len(query) > 0 {
+		configURL += "/?" + query.Encode()
+	}
+	// name the config after its remarks, or its ID if it has none
+	if name := config.Remarks; name != "" {
+		configURL += "#" + url.PathEscape(name)
+	} else if config.ID != "" {
+		configURL += "#" + url.PathEscape(config.ID)
+	}
+	return configURL, nil
+}
+
+// parseShadowsocksURL decodes a SIP002 URL, or a legacy ss://BASE64#tag URL,
+// into the server it describes. The fragment is returned as the remarks.
+func parseShadowsocksURL(configURL string) (*ServerInfo, error) {
+	u, err := url.Parse(strings.TrimSpace(configURL))
+	if err != nil {
+		return nil, fmt.Errorf("invalid shadowsocks URL")
+	}
+	if u.Scheme != "ss" {
+		return nil, fmt.Errorf("not a shadowsocks URL")
+	}
+	if u.User == nil {
+		// legacy format, with everything but the tag base64 encoded. Standard
+		// base64 may contain '/', so the encoded part is cut from the raw text.
+		encoded := strings.TrimPrefix(strings.TrimSpace(configURL), "ss://")
+		encoded, _, _ = strings.Cut(encoded, "#")
+		encoded, _, _ = strings.Cut(encoded, "?")
+		decoded, _, err := decodeBase64(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid legacy shadowsocks URL")
+		}
+		legacy, err := url.Parse("ss://" + string(decoded))
+		if err != nil || legacy.User == nil {
+			return nil, fmt.Errorf("invalid legacy shadowsocks URL")
+		}
+		legacy.RawQuery, legacy.Fragment = u.RawQuery, u.Fragment
+		u = legacy
+	}
+	var method, password string
+	if p, ok := u.User.Password(); ok {
+		// SIP002 allows percent-encoded plain userinfo, as used by AEAD-2022 ciphers
+		method, password = u.User.Username(), p
+	} else {
+		decoded, _, err := decodeBase64(u.User.Username())
+		if err != nil {
+			return nil, fmt.Errorf("invalid shadowsocks userinfo")
+		}
+		var found bool
+		method, password, found = strings.Cut(string(decoded), ":")
+		if !found {
+			return nil, fmt.Errorf("invalid shadowsocks userinfo: no ':' separator")
+		}
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		return nil, fmt.Errorf("invalid server port")
+	}
+	info := &ServerInfo{
+		Remarks:    u.Fragment,
+		Server:     u.Hostname(),
+		ServerPort: port,
+		Password:   password,
+		Method:     method,
+	}
+	query := u.Query()
+	info.Prefix = query.Get("prefix")
+	info.Plugin, info.PluginOpts, _ = strings.Cut(query.Get("plugin"), ";")
+	return info, nil
+}
+
+// isValidServer reports whether server is an IP address or a host name that
+// can be put in a URL unescaped.
+func isValidServer(server string) bool {
+	if ip := net.ParseIP(server); ip != nil {
+		return true
+	}
+	for _, r := range server {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
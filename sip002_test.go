@@ -0,0 +1,135 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Jigsaw-Code/outline-sdk/x/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShadowsocksURLRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		info ServerInfo
+	}{
+		{"plain", ServerInfo{Server: "example.com", ServerPort: 8388, Method: "chacha20-ietf-poly1305", Password: "secret"}},
+		{"special password", ServerInfo{Server: "example.com", ServerPort: 443, Method: "aes-256-gcm", Password: "p@ss:w/rd?#&=%+ ü"}},
+		{"prefix", ServerInfo{Server: "1.2.3.4", ServerPort: 443, Method: "chacha20-ietf-poly1305", Password: "secret", Prefix: "\x16\x03\x01\x00\u00a8\x01\x01"}},
+		{"plugin only", ServerInfo{Server: "example.com", ServerPort: 443, Method: "chacha20-ietf-poly1305", Password: "secret", Plugin: "obfs-local"}},
+		{"plugin opts", ServerInfo{Server: "example.com", ServerPort: 443, Method: "chacha20-ietf-poly1305", Password: "secret", Plugin: "v2ray-plugin", PluginOpts: "mode=websocket;host=example.org;path=/ws"}},
+		{"remarks", ServerInfo{Server: "example.com", ServerPort: 443, Method: "chacha20-ietf-poly1305", Password: "secret", Remarks: "Tokyo #1 / 東京"}},
+		{"ipv6", ServerInfo{Server: "2001:db8::1", ServerPort: 8388, Method: "chacha20-ietf-poly1305", Password: "secret"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configURL, err := makeShadowsocksURLfromJSON(&tt.info)
+			require.NoError(t, err)
+			decoded, err := parseShadowsocksURL(configURL)
+			require.NoError(t, err)
+			assert.Equal(t, tt.info, *decoded)
+
+			// The outline config parser must accept the encoded URL
+			transport, _ := splitTransportName(configURL)
+			_, err = config.NewStreamDialer(transport)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestMakeShadowsocksURLfromJSON(t *testing.T) {
+	info := ServerInfo{ID: "server-1", Server: "example.com", ServerPort: 443, Method: "chacha20-ietf-poly1305", Password: "a@b", Plugin: "obfs-local"}
+	configURL, err := makeShadowsocksURLfromJSON(&info)
+	require.NoError(t, err)
+	assert.Equal(t, "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTphQGI@example.com:443/?plugin=obfs-local#server-1", configURL)
+
+	invalid := []struct {
+		name    string
+		info    ServerInfo
+		wantErr string
+	}{
+		{"missing port", ServerInfo{Server: "example.com", Method: "aes-256-gcm", Password: "secret"}, "missing server port"},
+		{"port range", ServerInfo{Server: "example.com", ServerPort: 70000, Method: "aes-256-gcm", Password: "secret"}, "invalid server port"},
+		{"missing method", ServerInfo{Server: "example.com", ServerPort: 443, Password: "secret"}, "missing method"},
+		{"missing password", ServerInfo{Server: "example.com", ServerPort: 443, Method: "aes-256-gcm"}, "missing password"},
+		{"missing server", ServerInfo{ServerPort: 443, Method: "aes-256-gcm", Password: "secret"}, "missing server"},
+		{"server with path", ServerInfo{Server: "example.com/x", ServerPort: 443, Method: "aes-256-gcm", Password: "secret"}, "invalid server"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := makeShadowsocksURLfromJSON(&tt.info)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestParseShadowsocksURL(t *testing.T) {
+	want := ServerInfo{Server: "192.168.100.1", ServerPort: 8888, Method: "aes-128-gcm", Password: "test"}
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"base64url", "ss://YWVzLTEyOC1nY206dGVzdA@192.168.100.1:8888"},
+		{"padded base64", "ss://YWVzLTEyOC1nY206dGVzdA==@192.168.100.1:8888"},
+		{"plain userinfo", "ss://aes-128-gcm:test@192.168.100.1:8888"},
+		{"legacy", "ss://YWVzLTEyOC1nY206dGVzdEAxOTIuMTY4LjEwMC4xOjg4ODg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := parseShadowsocksURL(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, want, *info)
+		})
+	}
+
+	info, err := parseShadowsocksURL("ss://YWVzLTEyOC1nY206dGVzdEAxOTIuMTY4LjEwMC4xOjg4ODg#Legacy%20Tag")
+	require.NoError(t, err)
+	assert.Equal(t, "Legacy Tag", info.Remarks)
+
+	for _, input := range []string{"socks5://example.com:1080", "ss://bm90LXZhbGlk@example.com:443", "ss://YWVzLTEyOC1nY206dGVzdA@example.com", "ss://%%%"} {
+		_, err := parseShadowsocksURL(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func FuzzShadowsocksURLRoundTrip(f *testing.F) {
+	f.Add("chacha20-ietf-poly1305", "secret", "example.com", 443, "", "", "", "")
+	f.Add("aes-256-gcm", "p@ss:w/rd", "1.2.3.4", 8388, "POST ", "obfs-local", "obfs=http", "My Server")
+	f.Add("aes-128-gcm", "#?&=%", "2001:db8::1", 1, "\x16\x03\x01", "v2ray-plugin", "path=/;host=a", "東京 #1")
+	f.Fuzz(func(t *testing.T, method, password, server string, port int, prefix, plugin, pluginOpts, remarks string) {
+		info := ServerInfo{Server: server, ServerPort: port, Method: method, Password: password, Prefix: prefix, Plugin: plugin, PluginOpts: pluginOpts, Remarks: remarks}
+		configURL, err := makeShadowsocksURLfromJSON(&info)
+		if err != nil {
+			return
+		}
+		if info.Plugin == "" {
+			// options without a plugin are not encoded
+			info.PluginOpts = ""
+		}
+		decoded, err := parseShadowsocksURL(configURL)
+		require.NoError(t, err, configURL)
+		assert.Equal(t, info, *decoded, configURL)
+		assert.False(t, strings.ContainsAny(configURL, " \n"), configURL)
+	})
+}
+
+func FuzzParseShadowsocksURL(f *testing.F) {
+	f.Add("ss://YWVzLTEyOC1nY206dGVzdA@192.168.100.1:8888/?plugin=obfs-local#tag")
+	f.Add("ss://YWVzLTEyOC1nY206dGVzdEAxOTIuMTY4LjEwMC4xOjg4ODg")
+	f.Fuzz(func(t *testing.T, input string) {
+		info, err := parseShadowsocksURL(input)
+		if err != nil {
+			return
+		}
+		// Whatever decodes must encode again unless it is incomplete or unsafe
+		configURL, err := makeShadowsocksURLfromJSON(info)
+		if err != nil {
+			return
+		}
+		again, err := parseShadowsocksURL(configURL)
+		require.NoError(t, err)
+		assert.Equal(t, info.Server, again.Server)
+		assert.Equal(t, info.Password, again.Password)
+	})
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// maxDynamicKeySize limits the size of a fetched dynamic access key document.
+const maxDynamicKeySize = 64 << 10
+
+// dynamicKeyDocumentURL maps an ssconf:// key URL to the https:// URL of its document.
+func dynamicKeyDocumentURL(keyURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(keyURL))
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "ssconf" {
+		return "", fmt.Errorf("not an ssconf:// URL")
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("missing host in dynamic access key")
+	}
+	u.Scheme = "https"
+	u.Fragment = ""
+	return u.String(), nil
+}
+
+// fetchDynamicKey fetches the document an ssconf:// key points to and returns its
+// transport. The document is either an Outline JSON access key or an ss:// URL.
+func fetchDynamicKey(keyURL string, f *fetcher) (string, error) {
+	documentURL, err := dynamicKeyDocumentURL(keyURL)
+	if err != nil {
+		return "", err
+	}
+	response, err := f.get(documentURL, nil, maxDynamicKeySize)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch dynamic access key: %w", err)
+	}
+	text := strings.TrimSpace(string(response.Body))
+	if strings.HasPrefix(text, "ss://") {
+		return text, nil
+	}
+	return parseSingleJSON([]byte(text))
+}
+
+// refreshDynamicKey re-fetches the config's dynamic access key, so that it picks up
+// servers rotated by the provider. Configs without a key URL are left untouched.
+func refreshDynamicKey(c *Config, f *fetcher) error {
+	if c.KeyURL == "" {
+		return nil
+	}
+	transport, err := fetchDynamicKey(c.KeyURL, f)
+	if err != nil {
+		return err
+	}
+	transport, _ = splitTransportName(transport)
+	if transport != c.Transport {
+		c.Transport = transport
+		c.Health = 0
+		c.TestReports = []*connectivityReport{}
+	}
+	return nil
+}
+
+// refreshDynamicKeyOf refreshes the dynamic access key of the config with the
+// given ID. The key is fetched on a copy, without holding settingsMutex.
+func (s *AppSettings) refreshDynamicKeyOf(id string) error {
+	c, ok := s.configByID(id)
+	if !ok || c.KeyURL == "" {
+		return nil
+	}
+	f, err := s.configFetcher()
+	if err != nil {
+		return err
+	}
+	keyURL := c.KeyURL
+	if err := refreshDynamicKey(&c, f); err != nil {
+		return err
+	}
+	s.updateConfig(id, func(stored *Config) {
+		if stored.KeyURL == keyURL && stored.Transport != c.Transport {
+			stored.Transport, stored.Health, stored.TestReports = c.Transport, c.Health, c.TestReports
+		}
+	})
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDynamicKeyServer serves body on /key with newFetchServer.
+func newDynamicKeyServer(t *testing.T, body *string) string {
+	serverURL := newFetchServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/key" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(*body))
+	})
+	return "ssconf://" + strings.TrimPrefix(serverURL, "https://")
+}
+
+func TestParseInputTextDynamicKeyJSON(t *testing.T) {
+	body := `{"server": "example.com", "server_port": 8388, "password": "secret", "method": "chacha20-ietf-poly1305", "prefix": "POST "}`
+	keyURL := newDynamicKeyServer(t, &body) + "/key"
+
+	configs, err := parseInputText(keyURL+"#My%20Server", directFetcher())
+	require.NoError(t, err)
+	require.Len(t, configs, 1)
+	assert.Equal(t, keyURL, configs[0].KeyURL)
+	assert.Equal(t, "My Server", configs[0].Name)
+	assert.True(t, strings.HasPrefix(configs[0].Transport, "ss://"))
+	assert.Contains(t, configs[0].Transport, "example.com:8388")
+	assert.Contains(t, configs[0].Transport, "prefix=POST")
+}
+
+func TestParseInputTextDynamicKeyErrors(t *testing.T) {
+	body := `{"server": "example.com"}`
+	keyURL := newDynamicKeyServer(t, &body)
+
+	_, err := parseInputText(keyURL+"/key", directFetcher())
+	assert.Error(t, err)
+	_, err = parseInputText(keyURL+"/missing", directFetcher())
+	assert.ErrorContains(t, err, "404")
+}
+
+func TestRefreshDynamicKey(t *testing.T) {
+	body := "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpzZWNyZXQ@old.example.com:443"
+	keyURL := newDynamicKeyServer(t, &body) + "/key"
+	configs, err := parseInputText(keyURL, directFetcher())
+	require.NoError(t, err)
+	c := configs[0]
+	c.Health = 1
+
+	body = "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpzZWNyZXQ@new.example.com:443"
+	require.NoError(t, refreshDynamicKey(&c, directFetcher()))
+	assert.Equal(t, body, c.Transport)
+	assert.Equal(t, 0, c.Health)
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// defaultRefreshMinutes is the refresh interval of new subscriptions.
+const defaultRefreshMinutes = 24 * 60
+
+// maxSubscriptionSize limits the size of a fetched config list.
+const maxSubscriptionSize = 4 << 20
+
+// subscriptionCheckInterval is how often the scheduler looks for subscriptions due for a refresh.
+const subscriptionCheckInterval = time.Minute
+
+// Subscription is a remote config list. It owns the configs whose Subscription
+// field is its URL, and replaces them with the current list on every refresh.
+type Subscription struct {
+	URL          string    `json:"url"`
+	Name         string    `json:"name"`
+	LastFetched  time.Time `json:"lastFetched"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	// RefreshMinutes is the refresh interval. Zero disables scheduled refreshes.
+	RefreshMinutes int    `json:"refreshMinutes"`
+	LastError      string `json:"lastError,omitempty"`
+	// Headers are extra request headers of the list, such as a token. They are
+	// only sent to the origin of URL and may replace the User-Agent, as some
+	// servers pick the list format by it.
+	Headers map[string]string `json:"headers,omitempty"`
+	// lastAttempt keeps failing subscriptions from being retried before their next interval.
+	lastAttempt time.Time
+}
+
+// subscriptionDiff summarizes the changes of a subscription refresh.
+type subscriptionDiff struct {
+	Name      string
+	Added     []string
+	Removed   []string
+	Unchanged int
+	// Duplicates lists listed configs skipped as already in the configs of the user or another list.
+	Duplicates  []string
+	NotModified bool
+	// Import is the validated list the configs were merged from.
+	Import *importResult
+}
+
+// HasChanges reports whether the refresh added or removed configs.
+func (d subscriptionDiff) HasChanges() bool {
+	return len(d.Added) > 0 || len(d.Removed) > 0
+}
+
+// Summary describes the changes for the user.
+func (d subscriptionDiff) Summary() string {
+	if d.NotModified {
+		return fmt.Sprintf("%v: not modified since the last refresh", d.Name)
+	}
+	lines := []string{fmt.Sprintf("%v: %d added, %d removed, %d unchanged", d.Name, len(d.Added), len(d.Removed), d.Unchanged)}
+	if len(d.Added) > 0 {
+		lines = append(lines, "Added: "+strings.Join(d.Added, ", "))
+	}
+	if len(d.Removed) > 0 {
+		lines = append(lines, "Removed: "+strings.Join(d.Removed, ", "))
+	}
+	if len(d.Duplicates) > 0 {
+		lines = append(lines, "Already in the list: "+strings.Join(d.Duplicates, ", "))
+	}
+	if d.Import != nil {
+		lines = append(lines, "", d.Import.Summary())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// subscriptionConfigs turns the transports of a config list into configs owned by the subscription.
+func subscriptionConfigs(subscriptionURL string, configStrings []string) []Config {
+	var c []Config
+	for _, configString := range configStrings {
+		transport, name := splitTransportName(configString)
+		c = append(c, Config{Name: name, Transport: transport, Subscription: subscriptionURL, Health: 0, TestReports: []*connectivityReport{}})
+	}
+	return c
+}
+
+// fetchSubscription fetches and validates the config list of the subscription.
+// It returns nil if the server reports that the list is unchanged since the last fetch.
+func fetchSubscription(sub *Subscription, f *fetcher) (*importResult, error) {
+	header := http.Header{}
+	for key, value := range sub.Headers {
+		header.Set(key, value)
+	}
+	if sub.ETag != "" {
+		header.Set("If-None-Match", sub.ETag)
+	}
+	if sub.LastModified != "" {
+		header.Set("If-Modified-Since", sub.LastModified)
+	}
+	response, err := f.get(sub.URL, header, maxSubscriptionSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch subscription: %w", err)
+	}
+	if response.StatusCode == http.StatusNotModified {
+		sub.LastFetched = time.Now()
+		return nil, nil
+	}
+	// A list without valid configs is an error, so that it does not remove all configs
+	result, err := parseDynamicConfig(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	debugLog.Printf("Subscription %v is a %v", sub.Name, result.Format)
+	sub.ETag = response.Header.Get("ETag")
+	sub.LastModified = response.Header.Get("Last-Modified")
+	sub.LastFetched = time.Now()
+	return result, nil
+}
+
+// mergeSubscription replaces the configs owned by the subscription with the fetched
+// ones, compared by their normalized transports. Configs still in the list are kept
+// unchanged, with their transport, name, reports, history and health. Configs that
+// vanished are removed and new ones are appended. Fetched configs already among the
+// other configs are skipped.
+func mergeSubscription(configs []Config, subscriptionURL string, fetched []Config) ([]Config, subscriptionDiff) {
+	var diff subscriptionDiff
+	listed := make(map[string]bool)
+	for i := range fetched {
+		fetched[i].Transport = normalizeTransport(fetched[i].Transport)
+		listed[fetched[i].Transport] = true
+	}
+	seen := make(map[string]bool)
+	var merged []Config
+	for _, c := range configs {
+		transport := normalizeTransport(c.Transport)
+		if c.Subscription != subscriptionURL {
+			merged = append(merged, c)
+			continue
+		}
+		if listed[transport] && !seen[transport] {
+			seen[transport] = true
+			merged = append(merged, c)
+			diff.Unchanged++
+			continue
+		}
+		diff.Removed = append(diff.Removed, c.DisplayName())
+	}
+	for _, c := range fetched {
+		if seen[c.Transport] {
+			continue
+		}
+		seen[c.Transport] = true
+		if countDuplicates(merged, []Config{c}) > 0 {
+			diff.Duplicates = append(diff.Duplicates, c.DisplayName())
+			continue
+		}
+		merged = append(merged, c)
+		diff.Added = append(diff.Added, c.DisplayName())
+	}
+	ensureConfigIDs(merged)
+	return merged, diff
+}
+
+// findSubscription returns the index of the subscription with the given URL, or -1.
+// The caller holds settingsMutex.
+func (s *AppSettings) findSubscription(subscriptionURL string) int {
+	for i := range s.Subscriptions {
+		if s.Subscriptions[i].URL == subscriptionURL {
+			return i
+		}
+	}
+	return -1
+}
+
+// subscriptionUpdate is the outcome of fetching a subscription. It is fetched
+// without the settings lock and merged by applySubscriptionUpdate.
+type subscriptionUpdate struct {
+	// Subscription is a copy of the subscription with the fetch state updated.
+	Subscription Subscription
+	// Result is the fetched list, or nil if it was not modified or the fetch failed.
+	Result *importResult
+	Err    error
+}
+
+// fetchSubscriptionUpdate fetches a copy of sub.
+func (s *AppSettings) fetchSubscriptionUpdate(sub Subscription) subscriptionUpdate {
+	sub.lastAttempt = time.Now()
+	update := subscriptionUpdate{Subscription: sub}
+	f, err := s.configFetcher()
+	if err == nil {
+		update.Result, err = fetchSubscription(&update.Subscription, f)
+	}
+	update.Err = err
+	return update
+}
+
+// applySubscriptionUpdate stores the fetch state of a fetched subscription and
+// merges its list into the configs under the settings lock. Updates of
+// subscriptions removed while they were fetched are dropped.
+func (s *AppSettings) applySubscriptionUpdate(update subscriptionUpdate) (subscriptionDiff, error) {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	sub := update.Subscription
+	diff := subscriptionDiff{Name: sub.Name}
+	i := s.findSubscription(sub.URL)
+	if i < 0 {
+		return diff, fmt.Errorf("subscription %v was removed", sub.Name)
+	}
+	// Settings changed while fetching, such as the refresh interval, are kept
+	stored := &s.Subscriptions[i]
+	stored.lastAttempt = sub.lastAttempt
+	if update.Err != nil {
+		stored.LastError = update.Err.Error()
+		return diff, update.Err
+	}
+	stored.LastError = ""
+	stored.ETag, stored.LastModified, stored.LastFetched = sub.ETag, sub.LastModified, sub.LastFetched
+	if update.Result == nil {
+		diff.NotModified = true
+		return diff, nil
+	}
+	s.Configs, diff = mergeSubscription(s.Configs, sub.URL, subscriptionConfigs(sub.URL, update.Result.Accepted))
+	diff.Name = stored.Name
+	diff.Import = update.Result
+	return diff, nil
+}
+
+// AddSubscription subscribes to the config list at subscriptionURL, fetched with
+// the extra headers, and imports it. Adding a URL that is already subscribed
+// refreshes it instead of duplicating its configs, with the headers if any are given.
+func (s *AppSettings) AddSubscription(subscriptionURL string, headers map[string]string) (subscriptionDiff, error) {
+	u, err := url.Parse(strings.TrimSpace(subscriptionURL))
+	if err != nil {
+		return subscriptionDiff{}, err
+	}
+	if u.Scheme != "https" {
+		return subscriptionDiff{}, fmt.Errorf("subscriptions must use https")
+	}
+	settingsMutex.RLock()
+	subscribed := s.findSubscription(u.String()) >= 0
+	settingsMutex.RUnlock()
+	if subscribed {
+		if len(headers) > 0 {
+			s.setSubscriptionHeaders(u.String(), headers)
+		}
+		return s.RefreshSubscription(u.String())
+	}
+	update := s.fetchSubscriptionUpdate(Subscription{URL: u.String(), Name: u.Host, RefreshMinutes: defaultRefreshMinutes, Headers: headers})
+	if update.Err != nil {
+		return subscriptionDiff{Name: u.Host}, update.Err
+	}
+	settingsMutex.Lock()
+	if s.findSubscription(u.String()) < 0 {
+		s.Subscriptions = append(s.Subscriptions, update.Subscription)
+	}
+	settingsMutex.Unlock()
+	return s.applySubscriptionUpdate(update)
+}
+
+// setSubscriptionHeaders sets the extra request headers of the subscription.
+func (s *AppSettings) setSubscriptionHeaders(subscriptionURL string, headers map[string]string) {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	if i := s.findSubscription(subscriptionURL); i >= 0 {
+		s.Subscriptions[i].Headers = headers
+	}
+}
+
+// RefreshSubscription fetches the subscription with the given URL and merges its list into the configs.
+func (s *AppSettings) RefreshSubscription(subscriptionURL string) (subscriptionDiff, error) {
+	settingsMutex.RLock()
+	i := s.findSubscription(subscriptionURL)
+	if i < 0 {
+		settingsMutex.RUnlock()
+		return subscriptionDiff{}, fmt.Errorf("no subscription for %v", subscriptionURL)
+	}
+	sub := s.Subscriptions[i]
+	settingsMutex.RUnlock()
+	return s.applySubscriptionUpdate(s.fetchSubscriptionUpdate(sub))
+}
+
+// RemoveSubscription removes the subscription with the given URL together with its configs.
+func (s *AppSettings) RemoveSubscription(subscriptionURL string) error {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	i := s.findSubscription(subscriptionURL)
+	if i < 0 {
+		return fmt.Errorf("no subscription for %v", subscriptionURL)
+	}
+	s.Configs, _ = mergeSubscription(s.Configs, subscriptionURL, nil)
+	s.Subscriptions = slices.Delete(s.Subscriptions, i, i+1)
+	return nil
+}
+
+// subscriptionConfigCount returns the number of configs owned by the subscription.
+func (s *AppSettings) subscriptionConfigCount(subscriptionURL string) int {
+	settingsMutex.RLock()
+	defer settingsMutex.RUnlock()
+	count := 0
+	for _, c := range s.Configs {
+		if c.Subscription == subscriptionURL {
+			count++
+		}
+	}
+	return count
+}
+
+// isDue reports whether the subscription should be refreshed at the given time.
+func (sub *Subscription) isDue(now time.Time) bool {
+	if sub.RefreshMinutes <= 0 {
+		return false
+	}
+	last := sub.LastFetched
+	if sub.lastAttempt.After(last) {
+		last = sub.lastAttempt
+	}
+	return now.Sub(last) >= time.Duration(sub.RefreshMinutes)*time.Minute
+}
+
+// runSubscriptionRefresh fetches subscriptions as they become due and hands
+// every fetched update to apply, which merges it on the UI side.
+func runSubscriptionRefresh(ctx *AppContext, apply func(subscriptionUpdate)) {
+	for {
+		settingsMutex.RLock()
+		var due []Subscription
+		for _, sub := range ctx.Settings.Subscriptions {
+			if sub.isDue(time.Now()) {
+				due = append(due, sub)
+			}
+		}
+		settingsMutex.RUnlock()
+		for _, sub := range due {
+			apply(ctx.Settings.fetchSubscriptionUpdate(sub))
+		}
+		time.Sleep(subscriptionCheckInterval)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSubscriptionServer serves *body on /sub with the given ETag with newFetchServer.
+func newSubscriptionServer(t *testing.T, body *string, etag *string) string {
+	return newFetchServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/sub" {
+			http.NotFound(w, r)
+			return
+		}
+		if *etag != "" && r.Header.Get("If-None-Match") == *etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", *etag)
+		w.Write([]byte(*body))
+	}) + "/sub"
+}
+
+func subscriptionList(lines ...string) string {
+	return strings.Join(lines, "\n")
+}
+
+func TestSubscriptionRefreshMerge(t *testing.T) {
+	keyA := "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpzZWNyZXQ@a.example.com:443"
+	keyB := "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpzZWNyZXQ@b.example.com:443"
+	keyC := "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpzZWNyZXQ@c.example.com:443"
+	body := subscriptionList(keyA+"#A", keyB+"#B")
+	etag := `"v1"`
+	subURL := newSubscriptionServer(t, &body, &etag)
+
+	setting := &AppSettings{Configs: []Config{{Name: "Manual", Transport: testSSKey}}}
+	diff, err := setting.AddSubscription(subURL, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A", "B"}, diff.Added)
+	require.Len(t, setting.Subscriptions, 1)
+	require.Len(t, setting.Configs, 3)
+	assert.Equal(t, `"v1"`, setting.Subscriptions[0].ETag)
+
+	// The user renames and tests the first config of the list
+	setting.Configs[1].Name = "Renamed"
+	setting.Configs[1].Health = 1
+	setting.Configs[1].History = []testRun{{Time: time.Now(), Health: 1, LatencyMs: 50}}
+
+	// Unchanged lists are not merged again
+	diff, err = setting.AddSubscription(subURL, nil)
+	require.NoError(t, err)
+	assert.True(t, diff.NotModified)
+	assert.Len(t, setting.Subscriptions, 1)
+	assert.Len(t, setting.Configs, 3)
+
+	body = subscriptionList(keyA+"#A", keyC+"#C")
+	etag = `"v2"`
+	diff, err = setting.RefreshSubscription(subURL)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"C"}, diff.Added)
+	assert.Equal(t, []string{"B"}, diff.Removed)
+	assert.Equal(t, 1, diff.Unchanged)
+	assert.Contains(t, diff.Summary(), "1 added, 1 removed, 1 unchanged")
+
+	require.Len(t, setting.Configs, 3)
+	assert.Equal(t, "Manual", setting.Configs[0].Name)
+	assert.Equal(t, "Renamed", setting.Configs[1].Name)
+	assert.Equal(t, 1, setting.Configs[1].Health)
+	assert.Len(t, setting.Configs[1].History, 1)
+	assert.Equal(t, keyC, setting.Configs[2].Transport)
+	assert.Equal(t, subURL, setting.Configs[2].Subscription)
+}
+
+func TestRemoveSubscription(t *testing.T) {
+	other := "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpzZWNyZXQ@other.example.com:443"
+	body := subscriptionList(testSSKey+"#Listed", other+"#Other")
+	etag := ""
+	subURL := newSubscriptionServer(t, &body, &etag)
+
+	setting := &AppSettings{Configs: []Config{{Name: "Manual", Transport: testSSKey}}}
+	diff, err := setting.AddSubscription(subURL, nil)
+	require.NoError(t, err)
+	// The manual config is not duplicated by the list
+	assert.Equal(t, []string{"Listed"}, diff.Duplicates)
+	assert.Contains(t, diff.Summary(), "Already in the list: Listed")
+	require.Len(t, setting.Configs, 2)
+
+	require.NoError(t, setting.RemoveSubscription(subURL))
+	assert.Empty(t, setting.Subscriptions)
+	require.Len(t, setting.Configs, 1)
+	assert.Equal(t, "Manual", setting.Configs[0].Name)
+
+	// A subscription removed in the meantime, such as by a second confirm dialog, is reported
+	assert.Error(t, setting.RemoveSubscription(subURL))
+	require.Len(t, setting.Configs, 1)
+}
+
+func TestAddSubscriptionErrors(t *testing.T) {
+	body := "not a config list"
+	etag := ""
+	subURL := newSubscriptionServer(t, &body, &etag)
+
+	setting := &AppSettings{}
+	_, err := setting.AddSubscription(strings.Replace(subURL, "/sub", "/missing", 1), nil)
+	assert.ErrorContains(t, err, "404")
+	assert.Empty(t, setting.Subscriptions)
+
+	_, err = setting.AddSubscription("http://example.com/sub", nil)
+	assert.ErrorContains(t, err, "https")
+}
+
+func TestSubscriptionIsDue(t *testing.T) {
+	now := time.Now()
+	assert.False(t, (&Subscription{RefreshMinutes: 0}).isDue(now))
+	assert.True(t, (&Subscription{RefreshMinutes: 60, LastFetched: now.Add(-2 * time.Hour)}).isDue(now))
+	assert.False(t, (&Subscription{RefreshMinutes: 60, LastFetched: now.Add(-30 * time.Minute)}).isDue(now))
+	assert.False(t, (&Subscription{RefreshMinutes: 60, LastFetched: now.Add(-2 * time.Hour), lastAttempt: now}).isDue(now))
+}
+
+func TestSubscriptionRefreshKeepsConfigsOnInvalidList(t *testing.T) {
+	body := subscriptionList(testSSKey + "#Listed")
+	etag := ""
+	subURL := newSubscriptionServer(t, &body, &etag)
+
+	setting := &AppSettings{}
+	_, err := setting.AddSubscription(subURL, nil)
+	require.NoError(t, err)
+
+	body = "<html><body>Service Unavailable</body></html>"
+	_, err = setting.RefreshSubscription(subURL)
+	assert.ErrorContains(t, err, "HTML")
+	assert.Contains(t, setting.Subscriptions[0].LastError, "HTML")
+	require.Len(t, setting.Configs, 1)
+	assert.Equal(t, "Listed", setting.Configs[0].Name)
+}
+
+func TestSubscriptionRefreshKeepsSelection(t *testing.T) {
+	keyA := "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpzZWNyZXQ@a.example.com:443"
+	keyB := "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpzZWNyZXQ@b.example.com:443"
+	body := subscriptionList(keyA+"#A", keyB+"#B")
+	etag := ""
+	subURL := newSubscriptionServer(t, &body, &etag)
+	t.Cleanup(func() { selectedConfigID, resultConfigID = "", "" })
+
+	setting := &AppSettings{}
+	_, err := setting.AddSubscription(subURL, nil)
+	require.NoError(t, err)
+	require.Len(t, setting.Configs, 2)
+	selectedConfigID = setting.Configs[1].ID
+	resultConfigID = setting.Configs[0].ID
+
+	// B moves to the top of the list and stays selected
+	body = subscriptionList(keyB + "#B")
+	_, err = setting.RefreshSubscription(subURL)
+	require.NoError(t, err)
+	setting.forgetRemovedSelection()
+	require.Len(t, setting.Configs, 1)
+	assert.Equal(t, setting.Configs[0].ID, selectedConfigID)
+	assert.Empty(t, resultConfigID)
+
+	// The selection is cleared rather than moved to another config
+	body = subscriptionList(keyA + "#A")
+	_, err = setting.RefreshSubscription(subURL)
+	require.NoError(t, err)
+	setting.forgetRemovedSelection()
+	assert.Empty(t, selectedConfigID)
+}
+
+func TestApplySubscriptionUpdateOfRemovedSubscription(t *testing.T) {
+	body := subscriptionList(testSSKey + "#Listed")
+	etag := ""
+	subURL := newSubscriptionServer(t, &body, &etag)
+
+	setting := &AppSettings{}
+	_, err := setting.AddSubscription(subURL, nil)
+	require.NoError(t, err)
+
+	// The subscription is removed while its refresh is in flight
+	update := setting.fetchSubscriptionUpdate(setting.Subscriptions[0])
+	require.NoError(t, update.Err)
+	require.NoError(t, setting.RemoveSubscription(subURL))
+	_, err = setting.applySubscriptionUpdate(update)
+	assert.Error(t, err)
+	assert.Empty(t, setting.Configs)
+	assert.Empty(t, setting.Subscriptions)
+}
+
+func TestMergeSubscriptionKeepsStoredTransport(t *testing.T) {
+	subscriptionURL := "https://example.com/sub"
+	stored := "ss://chacha20-ietf-poly1305:secret@Example.com:443"
+	configs := []Config{{ID: "stored", Name: "Renamed", Transport: stored, Subscription: subscriptionURL}}
+
+	merged, diff := mergeSubscription(configs, subscriptionURL, subscriptionConfigs(subscriptionURL, []string{testSSKey + "#Listed"}))
+	assert.Equal(t, 1, diff.Unchanged)
+	assert.Empty(t, diff.Added)
+	assert.Equal(t, configs, merged)
+}
@@ -17,12 +17,13 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net"
 	"net/http"
 	"net/url"
-	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -31,7 +32,6 @@ import (
 	"github.com/Jigsaw-Code/outline-sdk/transport"
 	"github.com/Jigsaw-Code/outline-sdk/x/config"
 	"github.com/Jigsaw-Code/outline-sdk/x/connectivity"
-	"github.com/Jigsaw-Code/outline-sdk/x/report"
 )
 
 var debugLog log.Logger = *log.New(io.Discard, "", 0)
@@ -41,16 +41,30 @@ var debugLog log.Logger = *log.New(io.Discard, "", 0)
 // var errorLog log.Logger = *log.New(os.Stderr, "[ERROR] ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
 
 type connectivityReport struct {
+	SchemaVersion int `json:"schema_version"`
+
 	// Inputs
-	Resolver  string `json:"resolver"`
-	Proto     string `json:"proto"`
-	Transport string `json:"transport"`
+	Resolver   string `json:"resolver"`
+	Proto      string `json:"proto"`
+	Transport  string `json:"transport"`
+	TestType   string `json:"test_type,omitempty"`
+	TestDomain string `json:"test_domain,omitempty"`
+	ConfigHash string `json:"config_hash,omitempty"`
+	RunID      string `json:"run_id,omitempty"`
+
+	// Environment
+	AppVersion  string `json:"app_version,omitempty"`
+	OS          string `json:"os,omitempty"`
+	Arch        string `json:"arch,omitempty"`
+	NetworkType string `json:"network_type,omitempty"`
 
 	// Observations
-	Time       time.Time  `json:"time"`
-	DurationMs int64      `json:"duration_ms"`
-	Error      *errorJSON `json:"error"`
-	Collected  bool       `json:"collected"`
+	Time       time.Time       `json:"time"`
+	DurationMs int64           `json:"duration_ms"`
+	Error      *errorJSON      `json:"error"`
+	Category   failureCategory `json:"category,omitempty"`
+	// Deliveries records the result of sending the report to each collector destination.
+	Deliveries []deliveryResult `json:"deliveries,omitempty"`
 }
 
 type errorJSON struct {
@@ -88,61 +102,113 @@ func (r connectivityReport) IsSuccess() bool {
 	}
 }
 
-func TestConfigs(setting *AppSettings) {
+// TestConfigs tests all configs concurrently. It returns the errors of the
+// configs that could not be tested, after testing all others.
+func TestConfigs(setting *AppSettings) error {
 	var wg sync.WaitGroup // Step 1: Create a WaitGroup instance
+	var errsMutex sync.Mutex
+	var errs []error
+	runID := newRunID()
 
-	for i := range setting.Configs {
-		wg.Add(1)        // Increment the WaitGroup counter
-		go func(i int) { // Step 2: Launch a goroutine
+	for _, id := range setting.configIDs() {
+		wg.Add(1)            // Increment the WaitGroup counter
+		go func(id string) { // Step 2: Launch a goroutine
 			defer wg.Done() // Step 3: Decrement the counter when the goroutine completes
-			TestSingleConfig(setting, i)
-		}(i)
+			if err := testConfigInRun(setting, id, runID); err != nil {
+				errsMutex.Lock()
+				errs = append(errs, err)
+				errsMutex.Unlock()
+			}
+		}(id)
 	}
 
 	wg.Wait() // Step 4: Wait for all goroutines to complete
+	return errors.Join(errs...)
+}
+
+// TestSingleConfig tests the config with the given ID.
+func TestSingleConfig(setting *AppSettings, id string) error {
+	return testConfigInRun(setting, id, newRunID())
 }
 
-func TestSingleConfig(setting *AppSettings, i int) {
+// configIDs returns the IDs of the configs, giving IDs to configs that have none.
+func (s *AppSettings) configIDs() []string {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	ensureConfigIDs(s.Configs)
+	ids := make([]string, len(s.Configs))
+	for i := range s.Configs {
+		ids[i] = s.Configs[i].ID
+	}
+	return ids
+}
+
+// testConfigInRun tests the config with the given ID, tagging its reports with
+// runID. The test runs on a copy of the config, and its reports replace the
+// previous ones only once the test is complete, wherever the config moved in
+// the meantime. They are dropped if the config was removed or edited. A config
+// that cannot be parsed is marked as failing and returned as an error.
+func testConfigInRun(setting *AppSettings, id string, runID string) error {
 	var wg sync.WaitGroup
 	var healthlyMutex sync.Mutex
 	var healthly []bool
+	var reports []*connectivityReport
+	var throughputKbps int64
 	protocols := []string{"tcp", "udp"}
-	// check if i is within the range of the slice
-	if i < 0 || i >= len(setting.Configs) {
-		log.Fatalf("Index %v is out of range", i)
+	settingsMutex.RLock()
+	i := setting.configIndex(id)
+	var cnf Config
+	if i >= 0 {
+		cnf = setting.Configs[i]
+	}
+	domain, resolverHost, throughputURL := setting.Domain, setting.ResolverHost, setting.ThroughputURL
+	settingsMutex.RUnlock()
+	if i < 0 {
+		return fmt.Errorf("config %v was removed before its test", id)
 	}
-	cnf := &setting.Configs[i]
 	c, err := config.SanitizeConfig(cnf.Transport)
 	if err != nil {
-		log.Fatalf("Failed to sanitize config: %v", err)
+		setting.updateConfig(id, func(stored *Config) {
+			if stored.Transport == cnf.Transport {
+				stored.TestReports = []*connectivityReport{}
+				stored.Health = 3
+			}
+		})
+		return fmt.Errorf("config %v is invalid: %w", cnf.DisplayName(), err)
 	}
 	configparser := config.NewDefaultConfigParser()
-	// Clear previous test reports
-	// maybe make it atomic to prevent losing previous reports if test fails for any reason
-	// In other words, only clear the reports if the test is fully perfomed....
-	cnf.TestReports = []*connectivityReport{}
-	resolverHost := strings.TrimSpace(setting.ResolverHost)
+	resolverHost = strings.TrimSpace(resolverHost)
 	resolverAddress := net.JoinHostPort(resolverHost, "53")
 	for _, proto := range protocols {
 		wg.Add(1)
 		go func(proto string, resolverAddress string) {
 			defer wg.Done()
-			var r connectivityReport
+			r := newConnectivityReport(runID, cnf.Transport, domain)
+			startTime := time.Now()
+			// addFailedReport records a test that failed before it could run
+			addFailedReport := func(err error) {
+				r.Time = startTime.UTC().Truncate(time.Second)
+				r.DurationMs = time.Since(startTime).Milliseconds()
+				r.Error = &errorJSON{Msg: err.Error()}
+				r.Category = classifyError(r.Proto, r.Error)
+				healthlyMutex.Lock()
+				reports = append(reports, &r)
+				healthly = append(healthly, false)
+				healthlyMutex.Unlock()
+			}
 			var resolver dns.Resolver
+			var streamDialer transport.StreamDialer
 			r.Transport = c
 			r.Resolver = resolverAddress
-			startTime := time.Now()
 			switch proto {
 			case "tcp":
-				streamDialer, err := configparser.WrapStreamDialer(&transport.TCPDialer{}, cnf.Transport)
+				var err error
+				streamDialer, err = configparser.WrapStreamDialer(&transport.TCPDialer{}, cnf.Transport)
 				r.Proto = "tcp"
 				log.Printf("testing for protocol: %v", r.Proto)
 				if err != nil {
 					log.Printf("Failed to create StreamDialer: %v", err)
-					r.Time = startTime.UTC().Truncate(time.Second)
-					r.DurationMs = time.Duration(0).Milliseconds()
-					r.Error = &errorJSON{Msg: err.Error()}
-					cnf.TestReports = append(cnf.TestReports, &r)
+					addFailedReport(err)
 					return
 				}
 				resolver = dns.NewTCPResolver(streamDialer, resolverAddress)
@@ -152,106 +218,120 @@ func TestSingleConfig(setting *AppSettings, i int) {
 				log.Printf("testing for protocol: %v", r.Proto)
 				if err != nil {
 					log.Printf("Failed to create StreamDialer: %v", err)
-					r.Time = startTime.UTC().Truncate(time.Second)
-					r.DurationMs = time.Duration(0).Milliseconds()
-					r.Error = &errorJSON{Msg: err.Error()}
-					cnf.TestReports = append(cnf.TestReports, &r)
+					addFailedReport(err)
 					return
 				}
 				resolver = dns.NewUDPResolver(packetDialer, resolverAddress)
 			default:
-				log.Fatalf(`Invalid proto %v. Must be "tcp" or "udp"`, proto)
+				r.Proto = proto
+				addFailedReport(fmt.Errorf(`invalid proto %v, must be "tcp" or "udp"`, proto))
+				return
 			}
-			result, err := connectivity.TestConnectivityWithResolver(context.Background(), resolver, setting.Domain)
-			r.Time = startTime.UTC().Truncate(time.Second)
-			r.DurationMs = time.Duration(0).Milliseconds()
+			result, err := connectivity.TestConnectivityWithResolver(context.Background(), resolver, domain)
 			if err != nil {
-				log.Fatalf("Connectivity test failed to run: %v", err)
-				r.Error = &errorJSON{Msg: err.Error()}
-				cnf.TestReports = append(cnf.TestReports, &r)
+				log.Printf("Connectivity test failed to run: %v", err)
+				addFailedReport(err)
 				return
 			}
+			r.Time = startTime.UTC().Truncate(time.Second)
+			r.DurationMs = time.Since(startTime).Milliseconds()
 			r.Error = makeErrorRecord(result)
+			r.Category = classifyError(r.Proto, r.Error)
 			//log.Printf("Connectivity test result: %v", r)
 			// collectReport(r, "")
-			cnf.TestReports = append(cnf.TestReports, &r)
 
 			healthlyMutex.Lock()
+			reports = append(reports, &r)
 			healthly = append(healthly, r.IsSuccess())
 			healthlyMutex.Unlock()
 
+			// Tunnels that resolve over TCP are also timed downloading a file
+			if streamDialer != nil && r.IsSuccess() && throughputURL != "" {
+				kbps, err := measureThroughput(streamDialer, throughputURL)
+				if err != nil {
+					log.Printf("Throughput of %v not measured: %v", cnf.DisplayName(), err)
+				}
+				healthlyMutex.Lock()
+				throughputKbps = kbps
+				healthlyMutex.Unlock()
+			}
+
 		}(proto, resolverAddress)
 	}
 	wg.Wait()
-	cnf.Health = CheckHealth(healthly)
+	// Keep the protocols in a stable order
+	sort.Slice(reports, func(a, b int) bool { return reports[a].Proto < reports[b].Proto })
+	applied := setting.updateConfig(id, func(stored *Config) {
+		if stored.Transport != cnf.Transport {
+			return
+		}
+		stored.TestReports = reports
+		stored.Health = CheckHealth(healthly)
+		recordTestRun(stored, throughputKbps)
+	})
+	if !applied {
+		log.Printf("Config %v was removed during its test, dropping its reports", id)
+	}
+	return nil
 }
 
+// submitReports sends the test reports of all configs to the collectors.
 func submitReports(setting *AppSettings) {
 	log.Println("Submitting all reports...")
+	settings := setting.reportSettings()
 	var wg sync.WaitGroup // Create a WaitGroup instance
-	for i := range setting.Configs {
+	for _, id := range setting.configIDs() {
 		wg.Add(1)
-		sumbitOneReport(setting, i)
+		go func(id string) {
+			defer wg.Done()
+			sumbitOneReport(setting, settings, id)
+		}(id)
 	}
 	wg.Wait() // Wait for all goroutines to complete
 }
 
-func sumbitOneReport(setting *AppSettings, i int) {
+// sumbitOneReport sends the test reports of the config with the given ID to the
+// collectors of the report settings.
+func sumbitOneReport(setting *AppSettings, settings reportSettings, id string) {
 	var wg sync.WaitGroup
-	reporterURL := setting.ReporterURL
-	log.Printf("Reporter URL: %v", reporterURL)
-	c := setting.Configs[i]
-	log.Printf("Config: %v", c)
+	if !settings.Privacy.Consent {
+		log.Println("Report submission is not enabled, skipping")
+		return
+	}
+	c, ok := setting.configByID(id)
+	if !ok {
+		return
+	}
+	log.Printf("Config: %v", c.DisplayName())
 	for j := range c.TestReports {
 		wg.Add(1)                        // Increment the WaitGroup counter
 		go func(r *connectivityReport) { // Launch a goroutine
 			defer wg.Done() // Decrement the counter when the goroutine completes
-			err := collectReport(r, reporterURL)
+			err := collectReport(r, settings)
 			if err != nil {
 				debugLog.Printf("Failed to collect report: %v\n", err)
-				r.Collected = false
+				enqueueReport(setting, r)
 				return
 			}
 			log.Println("Report collected successfully")
-			r.Collected = true
-			log.Printf("Collecting report: %v", r)
 		}(c.TestReports[j])
 	}
 	wg.Wait() // Wait for all goroutines to complete
 }
 
-func collectReport(r report.Report, reporterURL string) error {
-	var reportCollector report.Collector
-	if strings.TrimSpace(reporterURL) != "" {
-		log.Println("URL is not empty, using remote collector...")
-		collectorURL, err := url.Parse(reporterURL)
-		if err != nil {
-			debugLog.Printf("Failed to parse collector URL: %v", err)
-		}
-		remoteCollector := &report.RemoteCollector{
-			CollectorURL: collectorURL,
-			HttpClient:   &http.Client{Timeout: 10 * time.Second},
-		}
-		retryCollector := &report.RetryCollector{
-			Collector:    remoteCollector,
-			MaxRetry:     3,
-			InitialDelay: 1 * time.Second,
-		}
-		reportCollector = &report.SamplingCollector{
-			Collector:       retryCollector,
-			SuccessFraction: 1,
-			FailureFraction: 1,
-		}
-	} else {
-		log.Println("No collector URL provided, writing to stdout")
-		reportCollector = &report.WriteCollector{Writer: os.Stdout}
+// reportHTTPClient returns the client used to send reports. If viaTunnel
+// is set and the proxy is running, reports are sent through the tunnel.
+func reportHTTPClient(viaTunnel bool) *http.Client {
+	client := &http.Client{Timeout: 10 * time.Second}
+	if !viaTunnel {
+		return client
 	}
-	log.Println("Collecting report...")
-	err := reportCollector.Collect(context.Background(), r)
-	if err != nil {
-		return err
+	if address := runningProxyAddress(); address != "" {
+		client.Transport = &http.Transport{
+			Proxy: http.ProxyURL(&url.URL{Scheme: "http", Host: address}),
+		}
 	}
-	return nil
+	return client
 }
 
 // CheckHealth takes a slice of booleans and returns:
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+)
+
+// defaultThroughputURL is downloaded through configs that pass their tests to
+// measure their throughput.
+const defaultThroughputURL = "https://speed.cloudflare.com/__down?bytes=1000000"
+
+const (
+	// throughputTimeout bounds a throughput measurement. A slower download is
+	// measured over the bytes received until then.
+	throughputTimeout = 10 * time.Second
+	// maxThroughputBytes is the most a throughput measurement downloads.
+	maxThroughputBytes = 1 << 20
+)
+
+// measureThroughput downloads throughputURL through the dialer and returns
+// the throughput in kbit/s.
+func measureThroughput(dialer transport.StreamDialer, throughputURL string) (int64, error) {
+	client := &http.Client{Timeout: throughputTimeout, Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialer.DialStream(ctx, addr)
+		},
+	}}
+	defer client.CloseIdleConnections()
+	start := time.Now()
+	response, err := client.Get(throughputURL)
+	if err != nil {
+		return 0, fmt.Errorf("throughput download failed: %w", errors.Unwrap(err))
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return 0, fmt.Errorf("throughput download responded %v", response.Status)
+	}
+	n, err := io.Copy(io.Discard, io.LimitReader(response.Body, maxThroughputBytes))
+	if n == 0 {
+		if err == nil {
+			err = fmt.Errorf("empty response")
+		}
+		return 0, fmt.Errorf("throughput download failed: %w", err)
+	}
+	elapsedMs := max(time.Since(start).Milliseconds(), 1)
+	// Bits per millisecond are kbit/s
+	return n * 8 / elapsedMs, nil
+}
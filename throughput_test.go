@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeasureThroughput(t *testing.T) {
+	payload := bytes.Repeat([]byte("a"), 256<<10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(payload)
+	}))
+	defer server.Close()
+
+	kbps, err := measureThroughput(&transport.TCPDialer{}, server.URL+"/file")
+	require.NoError(t, err)
+	assert.Greater(t, kbps, int64(0))
+
+	_, err = measureThroughput(&transport.TCPDialer{}, server.URL+"/missing")
+	assert.ErrorContains(t, err, "404")
+}